@@ -408,7 +408,7 @@ func Test_FileDownload(t *testing.T) {
 	url := "https://github.com/ccll/terraform-provider-virtualbox-images/releases/download/ubuntu-15.04/ubuntu-15.04.tar.xz"
 	homedir, _ := os.UserHomeDir()
 
-	path, err := pkg.FileDownload(url, homedir)
+	path, err := pkg.FileDownload(url, homedir, "")
 	if err != nil {
 		logrus.Fatalf("File Downloading failed: %s", err.Error())
 	}
@@ -432,21 +432,25 @@ func Test_UnpackImage(t *testing.T) {
 	url := "https://github.com/ccll/terraform-provider-virtualbox-images/releases/download/ubuntu-15.04/ubuntu-15.04.tar.xz"
 	homedir, _ := os.UserHomeDir()
 
-	path_to_archive, err := pkg.FileDownload(url, homedir)
+	path_to_archive, err := pkg.FileDownload(url, homedir, "")
 	if err != nil {
 		logrus.Fatalf("File Downloading failed: %s", err.Error())
 	}
 
 	defer os.Remove(path_to_archive)
 
-	_, err = pkg.UnpackImage(path_to_archive, homedir)
+	path, err := pkg.UnpackImage(path_to_archive, homedir, "")
 	if err != nil {
 		logrus.Fatalf("Unpacking Image failed: %s", err.Error())
 	}
 
-	defer os.Remove(filepath.Join(homedir, "ubuntu-15.04.vdi"))
+	defer os.RemoveAll(filepath.Join(homedir, "ubuntu-15.04"))
 
-	if _, err := os.Stat(filepath.Join(homedir, "ubuntu-15.04.vdi")); err != nil {
+	if path != filepath.Join(homedir, "ubuntu-15.04", "ubuntu-15.04.vdi") {
+		logrus.Fatalf("File path incorrect")
+	}
+
+	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
 			logrus.Fatalf("File does not exist")
 		} else {
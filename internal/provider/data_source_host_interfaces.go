@@ -0,0 +1,118 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/mixdone/terraform-provider-virtualbox/pkg"
+)
+
+// dataSourceHostInterfaces returns schema for data source listing host interfaces
+// which bridged network adapters can be attached to.
+func dataSourceHostInterfaces() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceHostInterfacesRead,
+
+		Schema: map[string]*schema.Schema{
+			"names": {
+				Description: "Names of host interfaces.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"interfaces": {
+				Description: "Host interfaces.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"mac_address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"ipv4_address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"ipv4_netmask": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"ipv6_address": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"ipv6_prefix_length": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"status": {
+							Description: "up | down | unknown",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"medium_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"wireless": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"dhcp": {
+							Description: "Whether interface gets its address by DHCP.",
+							Type:        schema.TypeBool,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// dataSourceHostInterfacesRead lists host interfaces.
+func dataSourceHostInterfacesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	hostInterfaces, err := pkg.HostInterfaces(vb)
+	if err != nil {
+		return diag.Errorf("Getting list of host interfaces failed: %s", err.Error())
+	}
+
+	names := make([]string, 0, len(hostInterfaces))
+	interfaces := make([]map[string]any, 0, len(hostInterfaces))
+	for _, hostInterface := range hostInterfaces {
+		names = append(names, hostInterface.Name)
+		interfaces = append(interfaces, map[string]any{
+			"name":               hostInterface.Name,
+			"mac_address":        hostInterface.MAC,
+			"ipv4_address":       hostInterface.IPv4Address,
+			"ipv4_netmask":       hostInterface.IPv4Netmask,
+			"ipv6_address":       hostInterface.IPv6Address,
+			"ipv6_prefix_length": hostInterface.IPv6PrefixLength,
+			"status":             hostInterface.Status,
+			"medium_type":        hostInterface.MediumType,
+			"wireless":           hostInterface.Wireless,
+			"dhcp":               hostInterface.DHCP,
+		})
+	}
+
+	d.SetId("bridgedifs")
+
+	if err := d.Set("names", names); err != nil {
+		return diag.Errorf("Didn't manage to set names: %s", err.Error())
+	}
+
+	if err := d.Set("interfaces", interfaces); err != nil {
+		return diag.Errorf("Didn't manage to set interfaces: %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,403 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/mixdone/terraform-provider-virtualbox/pkg"
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// dataSourceServerSchema returns computed attributes describing existing virtual machine.
+// it is shared by virtualbox_server and virtualbox_servers data sources.
+func dataSourceServerSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"uuid": {
+			Description: "UUID of Virtual Machine.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"name": {
+			Description: "Virtual Machine name.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"group": {
+			Description: "Group of Virtual Machine.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"status": {
+			Description: "Status of Virtual Machine.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"cpus": {
+			Description: "Amount of CPUs.",
+			Type:        schema.TypeInt,
+			Computed:    true,
+		},
+
+		"memory": {
+			Description: "RAW allocated for machine.",
+			Type:        schema.TypeInt,
+			Computed:    true,
+		},
+
+		"drag_and_drop": {
+			Description: "Drag and drop mode.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"clipboard": {
+			Description: "Clipboard mode.",
+			Type:        schema.TypeString,
+			Computed:    true,
+		},
+
+		"network_adapter": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"index": {
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+					"network_mode": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"nic_type": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"cable_connected": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"mac_address": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"promiscuous_mode": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"boot_priority": {
+						Type:     schema.TypeInt,
+						Computed: true,
+					},
+					"bandwidth_group": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"trace_file": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"nat_network": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"dns_proxy": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"dns_host_resolver": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+					"alias_mode": {
+						Type:     schema.TypeSet,
+						Computed: true,
+						Elem:     &schema.Schema{Type: schema.TypeString},
+					},
+					"tftp_prefix": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"tftp_file": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"bind_ip": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"port_forwarding": {
+						Type:     schema.TypeList,
+						Computed: true,
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"name": {
+									Type:     schema.TypeString,
+									Computed: true,
+								},
+								"protocol": {
+									Type:     schema.TypeString,
+									Computed: true,
+								},
+								"hostip": {
+									Type:     schema.TypeString,
+									Computed: true,
+								},
+								"hostport": {
+									Type:     schema.TypeInt,
+									Computed: true,
+								},
+								"guestip": {
+									Type:     schema.TypeString,
+									Computed: true,
+								},
+								"guestport": {
+									Type:     schema.TypeInt,
+									Computed: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+
+		"snapshot": {
+			Type:     schema.TypeList,
+			Computed: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"description": {
+						Type:     schema.TypeString,
+						Computed: true,
+					},
+					"current": {
+						Type:     schema.TypeBool,
+						Computed: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+// dataSourceServer returns schema for virtualbox_server data source.
+// it looks up existing virtual machine by UUID or name without managing it.
+func dataSourceServer() *schema.Resource {
+	s := dataSourceServerSchema()
+
+	s["uuid"].Description = "UUID of Virtual Machine to look up."
+	s["uuid"].Optional = true
+	s["uuid"].ExactlyOneOf = []string{"uuid", "name"}
+
+	s["name"].Description = "Name of Virtual Machine to look up."
+	s["name"].Optional = true
+	s["name"].ExactlyOneOf = []string{"uuid", "name"}
+
+	return &schema.Resource{
+		ReadContext: dataSourceServerRead,
+		Schema:      s,
+	}
+}
+
+// dataSourceServerRead reads information about existing virtual machine.
+func dataSourceServerRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	id := d.Get("uuid").(string)
+	if id == "" {
+		id = d.Get("name").(string)
+	}
+
+	vm, err := vb.VMInfo(id)
+	if err != nil {
+		return diag.Errorf("VMInfo failed: %s", err.Error())
+	}
+
+	server, err := flattenServer(vb, vm)
+	if err != nil {
+		return diag.Errorf("Reading VM %s failed: %s", id, err.Error())
+	}
+
+	d.SetId(vm.UUID)
+
+	for key, val := range server {
+		if err := d.Set(key, val); err != nil {
+			return diag.Errorf("Didn't manage to set %s: %s", key, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// dataSourceServers returns schema for virtualbox_servers data source.
+// it lists existing virtual machines filtered by name, group and state.
+func dataSourceServers() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceServersRead,
+
+		Schema: map[string]*schema.Schema{
+			"name_regex": {
+				Description: "Regular expression that names of Virtual Machines must match.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+
+			"group": {
+				Description: "Group that Virtual Machines must belong to.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+
+			"state": {
+				Description: "State that Virtual Machines must be in (poweroff | running | paused | saved | aborted).",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+
+			"ids": {
+				Description: "UUIDs of found Virtual Machines.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"servers": {
+				Description: "Found Virtual Machines.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: dataSourceServerSchema(),
+				},
+			},
+		},
+	}
+}
+
+// dataSourceServersRead lists virtual machines matching filters.
+func dataSourceServersRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	var nameRegex *regexp.Regexp
+	if val, ok := d.GetOk("name_regex"); ok {
+		var err error
+		if nameRegex, err = regexp.Compile(val.(string)); err != nil {
+			return diag.Errorf("Invalid name_regex: %s", err.Error())
+		}
+	}
+	group := d.Get("group").(string)
+	state := d.Get("state").(string)
+
+	vms, err := pkg.ListVMs(vb)
+	if err != nil {
+		return diag.Errorf("Getting list of VMs failed: %s", err.Error())
+	}
+
+	// Sorting by name so that order of servers is stable between reads
+	uuids := make([]string, 0, len(vms))
+	for uuid := range vms {
+		uuids = append(uuids, uuid)
+	}
+	sort.Slice(uuids, func(i, j int) bool {
+		return vms[uuids[i]] < vms[uuids[j]]
+	})
+
+	ids := make([]string, 0, len(vms))
+	servers := make([]map[string]any, 0, len(vms))
+	for _, uuid := range uuids {
+		name := vms[uuid]
+		if nameRegex != nil && !nameRegex.MatchString(name) {
+			continue
+		}
+
+		vm, err := vb.VMInfo(uuid)
+		if err != nil {
+			// VM could be unregistered after listing
+			continue
+		}
+
+		if state != "" && string(vm.Spec.State) != state {
+			continue
+		}
+
+		server, err := flattenServer(vb, vm)
+		if err != nil {
+			return diag.Errorf("Reading VM %s failed: %s", name, err.Error())
+		}
+
+		if group != "" && !inGroup(server["group"].(string), group) {
+			continue
+		}
+
+		ids = append(ids, uuid)
+		servers = append(servers, server)
+	}
+
+	d.SetId(fmt.Sprintf("%s|%s|%s", d.Get("name_regex").(string), group, state))
+
+	if err := d.Set("ids", ids); err != nil {
+		return diag.Errorf("Didn't manage to set ids: %s", err.Error())
+	}
+
+	if err := d.Set("servers", servers); err != nil {
+		return diag.Errorf("Didn't manage to set servers: %s", err.Error())
+	}
+
+	return nil
+}
+
+// flattenServer returns attributes of virtual machine in form of data source schema
+func flattenServer(vb *vbg.VBox, vm *vbg.VirtualMachine) (map[string]any, error) {
+	info, err := pkg.VMInfoMap(vb, vm.UUIDOrName())
+	if err != nil {
+		return nil, err
+	}
+
+	// Names of host-only, internal and bridged networks are not read by VMInfo
+	pkg.FillNICNames(vm, info)
+
+	settings, err := pkg.NICSettingsOf(vb, vm)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"uuid":            vm.UUID,
+		"name":            vm.Spec.Name,
+		"group":           info["groups"],
+		"status":          string(vm.Spec.State),
+		"cpus":            vm.Spec.CPU.Count,
+		"memory":          vm.Spec.Memory.SizeMB,
+		"drag_and_drop":   vm.Spec.DragAndDrop,
+		"clipboard":       vm.Spec.Clipboard,
+		"network_adapter": flattenNetwork(vm, settings),
+		"snapshot":        flattenSnapshots(vm),
+	}, nil
+}
+
+// inGroup checks whether comma separated list of VirtualBox groups contains group
+func inGroup(groups string, group string) bool {
+	for _, g := range strings.Split(groups, ",") {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
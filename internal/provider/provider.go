@@ -1,15 +1,167 @@
 package provider
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/mixdone/terraform-provider-virtualbox/pkg"
+	vbg "github.com/mixdone/virtualbox-go"
+	"github.com/sirupsen/logrus"
 )
 
+// Client holds provider-level settings shared by all resources.
+// it is built once in providerConfigure and passed to CRUD functions through m.
+type Client struct {
+	VBoxManagePath string
+	MachineFolder  string
+	DefaultBasedir string
+	ImageCache     *pkg.ImageCache
+}
+
+// VBox returns VirtualBox client whose base path is basedir inside machine folder.
+func (c *Client) VBox(basedir string) *vbg.VBox {
+	return vbg.NewVBox(vbg.Config{
+		BasePath:       c.MachinePath(basedir),
+		VirtualBoxPath: c.VBoxManagePath,
+	})
+}
+
+// MachinePath returns path to basedir inside machine folder.
+// absolute basedir is used as is.
+func (c *Client) MachinePath(basedir string) string {
+	if filepath.IsAbs(basedir) {
+		return basedir
+	}
+	return filepath.Join(c.MachineFolder, basedir)
+}
+
 func Provider() *schema.Provider {
 	return &schema.Provider{
+		Schema: map[string]*schema.Schema{
+			"vboxmanage_path": {
+				Description: "Folder containing VBoxManage executable. By default it is looked up in PATH.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VBOX_MANAGE_PATH", ""),
+			},
+
+			"machine_folder": {
+				Description: "Folder in which basedir of every virtual machine is created. Defaults to user home directory.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VBOX_MACHINE_FOLDER", ""),
+			},
+
+			"default_basedir": {
+				Description: "Basedir used by virtual machines that do not set their own.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "VMs",
+			},
+
+			"image_cache_dir": {
+				Description: "Folder in which files downloaded by url are cached. Defaults to user cache directory.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("VBOX_IMAGE_CACHE_DIR", ""),
+			},
+
+			"image_cache_max_size": {
+				Description: "Size of image cache in MB after which least recently used files are evicted, 0 means unlimited.",
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     0,
+			},
+
+			"image_cache_max_age": {
+				Description: "Time since last use after which cached files are evicted, e.g. 720h. Empty means unlimited.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+			},
+
+			"log_level": {
+				Description:  "Provider log level (panic | fatal | error | warn | info | debug | trace).",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "info",
+				ValidateFunc: validation.StringInSlice([]string{"panic", "fatal", "error", "warn", "info", "debug", "trace"}, false),
+			},
+		},
+
 		ResourcesMap: map[string]*schema.Resource{
-			"virtualbox_server":     resourceVM(),
-			"virtualbox_dhcp":       resourceDHCP(),
-			"virtualbox_natnetwork": resourceNatNetwork(),
+			"virtualbox_server":           resourceVM(),
+			"virtualbox_dhcp":             resourceDHCP(),
+			"virtualbox_natnetwork":       resourceNatNetwork(),
+			"virtualbox_disk":             resourceDisk(),
+			"virtualbox_appliance_export": resourceApplianceExport(),
+			"virtualbox_guest_property":   resourceGuestProperty(),
+			"virtualbox_hostonly_network": resourceHostOnlyNetwork(),
+			"virtualbox_internal_network": resourceInternalNetwork(),
+		},
+
+		DataSourcesMap: map[string]*schema.Resource{
+			"virtualbox_server":          dataSourceServer(),
+			"virtualbox_servers":         dataSourceServers(),
+			"virtualbox_host_interfaces": dataSourceHostInterfaces(),
 		},
+
+		ConfigureContextFunc: providerConfigure,
 	}
 }
+
+// providerConfigure builds Client from provider block.
+// returns diagnostic messages in case of errors.
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+	level, err := logrus.ParseLevel(d.Get("log_level").(string))
+	if err != nil {
+		return nil, diag.Errorf("Invalid log_level: %s", err.Error())
+	}
+	logrus.SetLevel(level)
+
+	client := &Client{
+		VBoxManagePath: d.Get("vboxmanage_path").(string),
+		MachineFolder:  d.Get("machine_folder").(string),
+		DefaultBasedir: d.Get("default_basedir").(string),
+	}
+
+	if client.VBoxManagePath != "" {
+		if err := pkg.SetVBoxManagePath(client.VBoxManagePath); err != nil {
+			return nil, diag.Errorf("Setting VBoxManage path failed: %s", err.Error())
+		}
+	}
+
+	if client.MachineFolder == "" {
+		homedir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, diag.Errorf("userhomedir failed: %s", err.Error())
+		}
+		client.MachineFolder = homedir
+	}
+
+	if err := os.MkdirAll(client.MachineFolder, 0740); err != nil {
+		return nil, diag.Errorf("Creation machine folder failed: %s", err.Error())
+	}
+
+	client.ImageCache = &pkg.ImageCache{
+		Dir:       d.Get("image_cache_dir").(string),
+		MaxSizeMB: int64(d.Get("image_cache_max_size").(int)),
+	}
+	if client.ImageCache.Dir == "" {
+		client.ImageCache.Dir = pkg.DefaultCacheDir(client.MachineFolder)
+	}
+	if val := d.Get("image_cache_max_age").(string); val != "" {
+		maxAge, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, diag.Errorf("Invalid image_cache_max_age: %s", err.Error())
+		}
+		client.ImageCache.MaxAge = maxAge
+	}
+
+	return client, nil
+}
@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/mixdone/terraform-provider-virtualbox/pkg"
+)
+
+var regexpApplianceExt = regexp.MustCompile(`\.(ova|ovf)$`)
+
+// resourceApplianceExport returns schema for export of virtual machines to OVA/OVF appliance.
+// appliance is exported again whenever settings, disks or snapshots of source virtual machines change.
+func resourceApplianceExport() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceApplianceExportCreate,
+		ReadContext:   resourceApplianceExportRead,
+		DeleteContext: resourceApplianceExportDelete,
+
+		CustomizeDiff: resourceApplianceExportDiff,
+
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Description:  "Path of .ova or .ovf file to export to.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(regexpApplianceExt, "must end with .ova or .ovf"),
+			},
+
+			"vms": {
+				Description: "IDs or names of Virtual Machines to export, they must not be running.",
+				Type:        schema.TypeList,
+				Required:    true,
+				ForceNew:    true,
+				MinItems:    1,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"ovf_version": {
+				Description:  "0.9 | 1.0 | 2.0",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "1.0",
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(pkg.OVFVersions(), false),
+			},
+
+			"manifest": {
+				Description: "Write manifest with checksums of exported files.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				ForceNew:    true,
+			},
+
+			"product": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"product_url": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"vendor": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"vendor_url": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"version": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"description": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+
+			"source_hash": {
+				Description: "Hash of settings, attached media and current snapshot of Virtual Machines at the moment of export.",
+				Type:        schema.TypeString,
+				Computed:    true,
+				ForceNew:    true,
+			},
+		},
+	}
+}
+
+// resourceApplianceExportCreate exports virtual machines to appliance.
+func resourceApplianceExportCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	path := d.Get("path").(string)
+	vms := expandStringList(d.Get("vms").([]interface{}))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0740); err != nil {
+		return diag.Errorf("Creation appliance foldier failed: %s", err.Error())
+	}
+
+	opts := pkg.ExportOptions{
+		OVFVersion:  d.Get("ovf_version").(string),
+		Manifest:    d.Get("manifest").(bool),
+		Product:     d.Get("product").(string),
+		ProductURL:  d.Get("product_url").(string),
+		Vendor:      d.Get("vendor").(string),
+		VendorURL:   d.Get("vendor_url").(string),
+		Version:     d.Get("version").(string),
+		Description: d.Get("description").(string),
+	}
+
+	if err := pkg.ExportAppliance(vb, vms, path, opts); err != nil {
+		return diag.Errorf("Exporting appliance failed: %s", err.Error())
+	}
+
+	hash, err := sourceHash(m.(*Client), vms)
+	if err != nil {
+		return diag.Errorf("Reading VMs failed: %s", err.Error())
+	}
+
+	if err := d.Set("source_hash", hash); err != nil {
+		return diag.Errorf("Didn't manage to set source_hash: %s", err.Error())
+	}
+
+	d.SetId(path)
+
+	return resourceApplianceExportRead(ctx, d, m)
+}
+
+// resourceApplianceExportRead checks that exported appliance still exists.
+// source_hash is kept as it was at the moment of export, so that changes of VMs show up in plan.
+func resourceApplianceExportRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if _, err := os.Stat(d.Id()); os.IsNotExist(err) {
+		d.SetId("")
+	}
+	return nil
+}
+
+// resourceApplianceExportDelete removes exported files.
+func resourceApplianceExportDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	files, err := pkg.ApplianceFiles(d.Id())
+	if err != nil {
+		return diag.Errorf("Listing appliance files failed: %s", err.Error())
+	}
+
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return diag.Errorf("Can't remove %s: %s", file, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// resourceApplianceExportDiff plans export again when source VMs differ from exported ones
+func resourceApplianceExportDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if d.Id() == "" || !d.NewValueKnown("vms") {
+		return nil
+	}
+
+	hash, err := sourceHash(m.(*Client), expandStringList(d.Get("vms").([]interface{})))
+	if err != nil {
+		// VMs are probably being recreated, export is planned by changed vms then
+		return nil
+	}
+
+	if hash != d.Get("source_hash").(string) {
+		if err := d.SetNew("source_hash", hash); err != nil {
+			return err
+		}
+		return d.ForceNew("source_hash")
+	}
+
+	return nil
+}
+
+// sourceHash returns combined fingerprint of VMs
+func sourceHash(client *Client, vms []string) (string, error) {
+	vb := client.VBox("")
+
+	h := sha256.New()
+	for _, vm := range vms {
+		fingerprint, err := pkg.VMFingerprint(vb, vm)
+		if err != nil {
+			return "", fmt.Errorf("VM %s: %s", vm, err.Error())
+		}
+		fmt.Fprintln(h, fingerprint)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// expandStringList converts list of schema values to strings
+func expandStringList(list []interface{}) []string {
+	res := make([]string, 0, len(list))
+	for _, item := range list {
+		res = append(res, item.(string))
+	}
+	return res
+}
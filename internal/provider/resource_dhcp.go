@@ -2,13 +2,11 @@ package provider
 
 import (
 	"context"
-	"os"
 	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	vbg "github.com/mixdone/virtualbox-go"
-	"github.com/sirupsen/logrus"
 )
 
 // resourceDHCP returns schema for DHCP resource.
@@ -20,6 +18,9 @@ func resourceDHCP() *schema.Resource {
 		UpdateContext: dhcpServerUpdate,
 		DeleteContext: dhcpServerDelete,
 		Exists:        dhcpServerExists,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"server_ip": {
@@ -75,8 +76,7 @@ func dhcpServerCreate(ctx context.Context, d *schema.ResourceData, m interface{}
 	dhcp.NetworkName = d.Get("network_name").(string)
 	dhcp.Enabled = d.Get("enabled").(bool)
 
-	homedir, _ := os.UserHomeDir()
-	vb := vbg.NewVBox(vbg.Config{BasePath: homedir})
+	vb := m.(*Client).VBox("")
 	if _, err := vb.AddDHCPServer(dhcp); err != nil {
 		if !strings.Contains(err.Error(), "exists") {
 			return diag.Errorf("add dhcpserver failed: %s", err.Error())
@@ -90,14 +90,16 @@ func dhcpServerCreate(ctx context.Context, d *schema.ResourceData, m interface{}
 // dhcpServerRead reads DHCP server configuration.
 // it retrieves DHCP configuration parameters from VirtualBox API and sets them in resource data.
 func dhcpServerRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	homedir, _ := os.UserHomeDir()
-	vb := vbg.NewVBox(vbg.Config{BasePath: homedir})
-	dhcp, err := vb.DHCPInfo(d.Get("network_name").(string))
-	logrus.Info("hello4")
+	vb := m.(*Client).VBox("")
+	dhcp, err := vb.DHCPInfo(d.Id())
 	if err != nil {
 		return diag.Errorf("dhcpInfo failed: %s", err.Error())
 	}
 
+	if dhcp.NetworkName == "" {
+		return diag.Errorf("DHCP server for network %s not found", d.Id())
+	}
+
 	if err := d.Set("server_ip", dhcp.IPAddress); err != nil {
 		return diag.Errorf("Didn't manage to set server ip: %s", err.Error())
 	}
@@ -128,8 +130,7 @@ func dhcpServerRead(ctx context.Context, d *schema.ResourceData, m interface{})
 // dhcpServerUpdate updates DHCP server configuration.
 // it retrieves both old and new DHCP configurations, compares them, and modifies DHCP server.
 func dhcpServerUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	homedir, _ := os.UserHomeDir()
-	vb := vbg.NewVBox(vbg.Config{BasePath: homedir})
+	vb := m.(*Client).VBox("")
 	dhcpOld, err := vb.DHCPInfo(d.Get("network_name").(string))
 	if err != nil {
 		diag.Errorf("dhcpInfo failed: %s", err.Error())
@@ -176,8 +177,7 @@ func dhcpServerUpdate(ctx context.Context, d *schema.ResourceData, m interface{}
 // dhcpServerDelete deletes DHCP server.
 // it retrieves DHCP server configuration and removes it using VirtualBox API.
 func dhcpServerDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	homedir, _ := os.UserHomeDir()
-	vb := vbg.NewVBox(vbg.Config{BasePath: homedir})
+	vb := m.(*Client).VBox("")
 	dhcp, err := vb.DHCPInfo(d.Get("network_name").(string))
 	if err != nil {
 		diag.Errorf("dhcpInfo failed: %s", err.Error())
@@ -193,8 +193,7 @@ func dhcpServerDelete(ctx context.Context, d *schema.ResourceData, m interface{}
 // dhcpServerExists checks if DHCP server exists.
 // it verifies existence of DHCP server configuration.
 func dhcpServerExists(d *schema.ResourceData, m interface{}) (bool, error) {
-	homedir, _ := os.UserHomeDir()
-	vb := vbg.NewVBox(vbg.Config{BasePath: homedir})
+	vb := m.(*Client).VBox("")
 	_, err := vb.DHCPInfo(d.Get("network_name").(string))
 	if err != nil {
 		if !strings.Contains(err.Error(), "exists") {
@@ -0,0 +1,265 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/mixdone/terraform-provider-virtualbox/pkg"
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// resourceDisk returns schema for virtual disk resource.
+// disk lives on its own and can be attached to virtualbox_server by its ID,
+// so that it survives recreation of the virtual machine.
+func resourceDisk() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceDiskCreate,
+		ReadContext:   resourceDiskRead,
+		UpdateContext: resourceDiskUpdate,
+		DeleteContext: resourceDiskDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceDiskImport,
+		},
+
+		CustomizeDiff: customdiff.ValidateChange("size", func(ctx context.Context, old, new, m interface{}) error {
+			if old.(int) > new.(int) {
+				return fmt.Errorf("disk can not be shrunk from %d MB to %d MB", old.(int), new.(int))
+			}
+			return nil
+		}),
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description: "Name of disk file without extension.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+
+			"basedir": {
+				Description: "The folder in which the disk will be located.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "Disks",
+				ForceNew:    true,
+			},
+
+			"size": {
+				Description:  "Disk size in MB. Growing it resizes the disk.",
+				Type:         schema.TypeInt,
+				Required:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+
+			"format": {
+				Description:  "vdi | vmdk | vhd",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "vdi",
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(pkg.DiskFormats(), false),
+			},
+
+			"variant": {
+				Description:  "dynamic | fixed",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "dynamic",
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(pkg.DiskVariants(), false),
+			},
+
+			"clone_from": {
+				Description: "Path or UUID of the disk to copy.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+			},
+
+			"compact_trigger": {
+				Description: "Changing this value compacts the disk.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+
+			"path": {
+				Description: "Location of the disk.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// resourceDiskCreate creates new disk or clones existing one.
+func resourceDiskCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(*Client)
+	vb := client.VBox("")
+
+	dir := client.MachinePath(d.Get("basedir").(string))
+	if err := os.MkdirAll(dir, 0740); err != nil {
+		return diag.Errorf("Creation disk foldier failed: %s", err.Error())
+	}
+
+	format := d.Get("format").(string)
+	variant := d.Get("variant").(string)
+	size := int64(d.Get("size").(int))
+	path := filepath.Join(dir, d.Get("name").(string)+"."+format)
+
+	if src, ok := d.GetOk("clone_from"); ok {
+		if err := pkg.CloneDisk(vb, src.(string), path, format, variant); err != nil {
+			return diag.Errorf("Cloning disk failed: %s", err.Error())
+		}
+
+		medium, err := pkg.MediumInfo(vb, path)
+		if err != nil {
+			return diag.Errorf("Reading disk failed: %s", err.Error())
+		}
+
+		clonedSize, err := pkg.MediumSizeMB(medium)
+		if err != nil {
+			return diag.Errorf("Reading disk failed: %s", err.Error())
+		}
+
+		if size > clonedSize {
+			if err := pkg.ResizeDisk(vb, path, size); err != nil {
+				return diag.Errorf("Resizing disk failed: %s", err.Error())
+			}
+		}
+	} else {
+		if err := pkg.CreateDisk(vb, path, size, format, variant); err != nil {
+			return diag.Errorf("Creating disk failed: %s", err.Error())
+		}
+	}
+
+	disk, err := vb.DiskInfo(&vbg.Disk{Path: path, Type: vbg.HDDrive})
+	if err != nil {
+		return diag.Errorf("DiskInfo failed: %s", err.Error())
+	}
+
+	d.SetId(disk.UUID)
+
+	return resourceDiskRead(ctx, d, m)
+}
+
+// resourceDiskRead reads information about disk.
+func resourceDiskRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	disk, err := vb.DiskInfo(&vbg.Disk{UUID: d.Id(), Type: vbg.HDDrive})
+	if err != nil {
+		if vbg.IsDiskNotFound(err) || strings.Contains(err.Error(), "VBOX_E_OBJECT_NOT_FOUND") {
+			d.SetId("")
+			return nil
+		}
+		return diag.Errorf("DiskInfo failed: %s", err.Error())
+	}
+
+	medium, err := pkg.MediumInfo(vb, disk.UUID)
+	if err != nil {
+		return diag.Errorf("Reading disk failed: %s", err.Error())
+	}
+
+	size, err := pkg.MediumSizeMB(medium)
+	if err != nil {
+		return diag.Errorf("Reading disk failed: %s", err.Error())
+	}
+
+	if err := d.Set("path", disk.Path); err != nil {
+		return diag.Errorf("Didn't manage to set path: %s", err.Error())
+	}
+
+	if err := d.Set("size", int(size)); err != nil {
+		return diag.Errorf("Didn't manage to set size: %s", err.Error())
+	}
+
+	if err := d.Set("format", strings.ToLower(string(disk.Format))); err != nil {
+		return diag.Errorf("Didn't manage to set format: %s", err.Error())
+	}
+
+	if err := d.Set("variant", pkg.MediumVariant(medium)); err != nil {
+		return diag.Errorf("Didn't manage to set variant: %s", err.Error())
+	}
+
+	return nil
+}
+
+// resourceDiskUpdate grows disk and compacts it when compact_trigger changes.
+func resourceDiskUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	if d.HasChange("size") {
+		if err := pkg.ResizeDisk(vb, d.Id(), int64(d.Get("size").(int))); err != nil {
+			return diag.Errorf("Resizing disk failed: %s", err.Error())
+		}
+	}
+
+	if d.HasChange("compact_trigger") {
+		if err := pkg.CompactDisk(vb, d.Id()); err != nil {
+			return diag.Errorf("Compacting disk failed: %s", err.Error())
+		}
+	}
+
+	return resourceDiskRead(ctx, d, m)
+}
+
+// resourceDiskDelete deletes disk, disk must be detached from all virtual machines.
+func resourceDiskDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	medium, err := pkg.MediumInfo(vb, d.Id())
+	if err != nil {
+		return diag.Errorf("Reading disk failed: %s", err.Error())
+	}
+
+	if inUse := medium["In use by VMs"]; inUse != "" {
+		return diag.Errorf("Disk %s is still attached to VMs: %s", d.Id(), inUse)
+	}
+
+	if err := vb.DeleteDisk(d.Id()); err != nil {
+		return diag.Errorf("Deleting disk failed: %s", err.Error())
+	}
+
+	return nil
+}
+
+// resourceDiskImport adopts existing disk by UUID or path.
+func resourceDiskImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*Client)
+	vb := client.VBox("")
+
+	disk := &vbg.Disk{UUID: d.Id(), Type: vbg.HDDrive}
+	if filepath.IsAbs(d.Id()) {
+		disk = &vbg.Disk{Path: d.Id(), Type: vbg.HDDrive}
+	}
+
+	info, err := vb.DiskInfo(disk)
+	if err != nil {
+		return nil, fmt.Errorf("disk %s not found: %s", d.Id(), err.Error())
+	}
+
+	name := filepath.Base(info.Path)
+	if err := d.Set("name", strings.TrimSuffix(name, filepath.Ext(name))); err != nil {
+		return nil, fmt.Errorf("didn't manage to set name: %s", err.Error())
+	}
+
+	basedir := filepath.Dir(info.Path)
+	if rel, err := filepath.Rel(client.MachineFolder, basedir); err == nil && !strings.HasPrefix(rel, "..") {
+		basedir = rel
+	}
+
+	if err := d.Set("basedir", basedir); err != nil {
+		return nil, fmt.Errorf("didn't manage to set basedir: %s", err.Error())
+	}
+
+	d.SetId(info.UUID)
+
+	return []*schema.ResourceData{d}, nil
+}
@@ -0,0 +1,210 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/mixdone/terraform-provider-virtualbox/pkg"
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// resourceGuestProperty returns schema for single guest property of virtual machine.
+// ID of resource is "<VM UUID>:<property name>".
+func resourceGuestProperty() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceGuestPropertyCreate,
+		ReadContext:   resourceGuestPropertyRead,
+		UpdateContext: resourceGuestPropertyUpdate,
+		DeleteContext: resourceGuestPropertyDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceGuestPropertyImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"vm": {
+				Description:      "ID or name of Virtual Machine.",
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressSameVM,
+			},
+
+			"vm_name": {
+				Description: "Name of Virtual Machine, vm set to its name or ID refers to the same machine.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+
+			"name": {
+				Description: "Name of guest property, e.g. /build/id.",
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+			},
+
+			"value": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+
+			"flags": {
+				Description: "TRANSIENT | TRANSRESET | RDONLYGUEST | RDONLYHOST | READONLY",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice(pkg.GuestPropertyFlags(), false),
+				},
+			},
+		},
+	}
+}
+
+// resourceGuestPropertyCreate sets guest property.
+func resourceGuestPropertyCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	vm, err := vb.VMInfo(d.Get("vm").(string))
+	if err != nil {
+		return diag.Errorf("VMInfo failed: %s", err.Error())
+	}
+
+	if err := setGuestProperty(d, vb, vm); err != nil {
+		return diag.Errorf(err.Error())
+	}
+
+	d.SetId(vm.UUID + ":" + d.Get("name").(string))
+
+	return resourceGuestPropertyRead(ctx, d, m)
+}
+
+// resourceGuestPropertyRead reads value and flags of guest property.
+// resource is removed from state if property or virtual machine is gone.
+func resourceGuestPropertyRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	uuid, name, _ := strings.Cut(d.Id(), ":")
+	vm, err := vb.VMInfo(uuid)
+	if err != nil {
+		d.SetId("")
+		return nil
+	}
+
+	prop, ok, err := pkg.GetGuestProperty(vb, vm, name)
+	if err != nil {
+		return diag.Errorf("Reading guest property failed: %s", err.Error())
+	}
+	if !ok {
+		d.SetId("")
+		return nil
+	}
+
+	if err := d.Set("vm_name", vm.Spec.Name); err != nil {
+		return diag.Errorf("Didn't manage to set vm_name: %s", err.Error())
+	}
+
+	if err := d.Set("name", name); err != nil {
+		return diag.Errorf("Didn't manage to set name: %s", err.Error())
+	}
+
+	if err := d.Set("value", prop.Value); err != nil {
+		return diag.Errorf("Didn't manage to set value: %s", err.Error())
+	}
+
+	if err := d.Set("flags", pkg.SplitGuestPropertyFlags(prop.Flags)); err != nil {
+		return diag.Errorf("Didn't manage to set flags: %s", err.Error())
+	}
+
+	return nil
+}
+
+// resourceGuestPropertyUpdate sets new value or flags of guest property.
+func resourceGuestPropertyUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	uuid, _, _ := strings.Cut(d.Id(), ":")
+	vm, err := vb.VMInfo(uuid)
+	if err != nil {
+		return diag.Errorf("VMInfo failed: %s", err.Error())
+	}
+
+	if err := setGuestProperty(d, vb, vm); err != nil {
+		return diag.Errorf(err.Error())
+	}
+
+	return resourceGuestPropertyRead(ctx, d, m)
+}
+
+// resourceGuestPropertyDelete removes guest property, nothing is done if virtual machine is gone.
+func resourceGuestPropertyDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	uuid, name, _ := strings.Cut(d.Id(), ":")
+	vm, err := vb.VMInfo(uuid)
+	if err != nil {
+		return nil
+	}
+
+	if err := pkg.DeleteGuestProperty(vb, vm, name); err != nil {
+		return diag.Errorf("Deleting guest property failed: %s", err.Error())
+	}
+
+	return nil
+}
+
+// resourceGuestPropertyImport adopts guest property by "<VM UUID or name>:<property name>"
+func resourceGuestPropertyImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	vb := m.(*Client).VBox("")
+
+	vmID, name, ok := strings.Cut(d.Id(), ":")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("ID must look like <VM>:<property name>, got %s", d.Id())
+	}
+
+	vm, err := vb.VMInfo(vmID)
+	if err != nil {
+		return nil, fmt.Errorf("VM %s not found: %s", vmID, err.Error())
+	}
+
+	if err := d.Set("vm", vmID); err != nil {
+		return nil, fmt.Errorf("didn't manage to set vm: %s", err.Error())
+	}
+
+	if err := d.Set("vm_name", vm.Spec.Name); err != nil {
+		return nil, fmt.Errorf("didn't manage to set vm_name: %s", err.Error())
+	}
+
+	d.SetId(vm.UUID + ":" + name)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// setGuestProperty sets guest property from schema object.ResourceData
+func setGuestProperty(d *schema.ResourceData, vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	flags := expandStringList(d.Get("flags").(*schema.Set).List())
+	sort.Strings(flags)
+
+	if err := pkg.SetGuestProperty(vb, vm, d.Get("name").(string), d.Get("value").(string), strings.Join(flags, ",")); err != nil {
+		return fmt.Errorf("Setting guest property failed: %s", err.Error())
+	}
+	return nil
+}
+
+// suppressSameVM ignores change of vm between name and UUID of the same virtual machine,
+// e.g. property imported by UUID and configured by name
+func suppressSameVM(k, old, new string, d *schema.ResourceData) bool {
+	uuid, _, _ := strings.Cut(d.Id(), ":")
+	if uuid == "" {
+		return false
+	}
+
+	sameVM := func(v string) bool {
+		return v == uuid || v == d.Get("vm_name").(string)
+	}
+	return sameVM(old) && sameVM(new)
+}
@@ -0,0 +1,361 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/mixdone/terraform-provider-virtualbox/pkg"
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// resourceHostOnlyNetwork returns schema for host-only network resource.
+// name of host-only interface is chosen by VirtualBox, so it is computed and
+// can be passed to network_adapter.name of virtualbox_server.
+func resourceHostOnlyNetwork() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceHostOnlyNetworkCreate,
+		ReadContext:   resourceHostOnlyNetworkRead,
+		UpdateContext: resourceHostOnlyNetworkUpdate,
+		DeleteContext: resourceHostOnlyNetworkDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceHostOnlyNetworkImport,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"type": {
+				Description:  "hostonlyif | hostonlynet, hostonlynet is used on hosts without host-only interfaces, e.g. macOS.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "hostonlyif",
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice(pkg.HostOnlyTypes(), false),
+			},
+
+			"name": {
+				Description: "Name of network, required for hostonlynet and chosen by VirtualBox for hostonlyif.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+			},
+
+			"ipv4_address": {
+				Description:  "IPv4 address of host in network, hostonlyif only.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IsIPv4Address,
+			},
+
+			"ipv4_netmask": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "255.255.255.0",
+				ValidateFunc: validation.IsIPv4Address,
+			},
+
+			"ipv6_address": {
+				Description:  "IPv6 address of host in network, hostonlyif only.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IsIPv6Address,
+			},
+
+			"ipv6_prefix_length": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      64,
+				ValidateFunc: validation.IntBetween(1, 128),
+			},
+
+			"lower_ip": {
+				Description:  "Lower bound of addresses of network, hostonlynet only.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsIPv4Address,
+			},
+
+			"upper_ip": {
+				Description:  "Upper bound of addresses of network, hostonlynet only.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsIPv4Address,
+			},
+
+			"dhcp": {
+				Description: "DHCP server of host-only interface, hostonlyif only.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"server_ip": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.IsIPv4Address,
+						},
+						"lower_ip": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.IsIPv4Address,
+						},
+						"upper_ip": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.IsIPv4Address,
+						},
+						"enabled": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// resourceHostOnlyNetworkCreate creates host-only network and its DHCP server.
+func resourceHostOnlyNetworkCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if err := validateHostOnlyParams(d); err != nil {
+		return diag.Errorf(err.Error())
+	}
+
+	vb := m.(*Client).VBox("")
+
+	network := expandHostOnlyNetwork(d)
+	if err := pkg.CreateHostOnlyNetwork(vb, &network); err != nil {
+		return diag.Errorf("Creation host-only network failed: %s", err.Error())
+	}
+
+	d.SetId(network.Name)
+
+	if dhcp, ok := expandHostOnlyDHCP(d, network); ok {
+		if _, err := vb.AddDHCPServer(dhcp); err != nil {
+			return diag.Errorf("Adding DHCP server failed: %s", err.Error())
+		}
+	}
+
+	return resourceHostOnlyNetworkRead(ctx, d, m)
+}
+
+// resourceHostOnlyNetworkRead reads addresses of host-only network and its DHCP server.
+func resourceHostOnlyNetworkRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	networks, err := pkg.HostOnlyNetworks(vb, d.Get("type").(string))
+	if err != nil {
+		return diag.Errorf("Listing host-only networks failed: %s", err.Error())
+	}
+
+	network, ok := networks[d.Id()]
+	if !ok {
+		d.SetId("")
+		return nil
+	}
+
+	values := map[string]interface{}{
+		"name":         network.Name,
+		"ipv4_netmask": network.IPv4Netmask,
+	}
+	if network.Type == "hostonlyif" {
+		values["ipv4_address"] = network.IPv4Address
+		values["ipv6_address"] = network.IPv6Address
+		if network.IPv6Address != "" {
+			values["ipv6_prefix_length"] = network.IPv6PrefixLength
+		}
+	} else {
+		values["lower_ip"] = network.LowerIP
+		values["upper_ip"] = network.UpperIP
+	}
+	for key, value := range values {
+		if err := d.Set(key, value); err != nil {
+			return diag.Errorf("Didn't manage to set %s: %s", key, err.Error())
+		}
+	}
+
+	if network.Type == "hostonlyif" {
+		dhcp, err := vb.DHCPInfo(network.DHCPNetworkName())
+		if err != nil {
+			return diag.Errorf("dhcpInfo failed: %s", err.Error())
+		}
+
+		servers := []map[string]interface{}{}
+		if dhcp.NetworkName != "" {
+			servers = append(servers, map[string]interface{}{
+				"server_ip": dhcp.IPAddress,
+				"lower_ip":  dhcp.LowerIPAddress,
+				"upper_ip":  dhcp.UpperIPAddress,
+				"enabled":   dhcp.Enabled,
+			})
+		}
+		if err := d.Set("dhcp", servers); err != nil {
+			return diag.Errorf("Didn't manage to set dhcp: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// resourceHostOnlyNetworkUpdate applies new addresses and adds, modifies or removes DHCP server.
+func resourceHostOnlyNetworkUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	if err := validateHostOnlyParams(d); err != nil {
+		return diag.Errorf(err.Error())
+	}
+
+	vb := m.(*Client).VBox("")
+	network := expandHostOnlyNetwork(d)
+
+	if d.HasChanges("ipv4_address", "ipv4_netmask", "ipv6_address", "ipv6_prefix_length", "lower_ip", "upper_ip") {
+		if err := pkg.ConfigureHostOnlyNetwork(vb, network); err != nil {
+			return diag.Errorf("Configuring host-only network failed: %s", err.Error())
+		}
+	}
+
+	if d.HasChanges("dhcp", "ipv4_netmask") {
+		old, err := vb.DHCPInfo(network.DHCPNetworkName())
+		if err != nil {
+			return diag.Errorf("dhcpInfo failed: %s", err.Error())
+		}
+
+		dhcp, ok := expandHostOnlyDHCP(d, network)
+		switch {
+		case !ok && old.NetworkName != "":
+			if err := vb.RemoveDHCPServer(old.NetworkName); err != nil {
+				return diag.Errorf("Removing DHCP server failed: %s", err.Error())
+			}
+		case ok && old.NetworkName == "":
+			if _, err := vb.AddDHCPServer(dhcp); err != nil {
+				return diag.Errorf("Adding DHCP server failed: %s", err.Error())
+			}
+		case ok:
+			if err := vb.ModifyDHCPServer(dhcp, []string{"ip", "lowerip", "upperip", "netmask", "work"}); err != nil {
+				return diag.Errorf("Modify DHCP failed: %s", err.Error())
+			}
+		}
+	}
+
+	return resourceHostOnlyNetworkRead(ctx, d, m)
+}
+
+// resourceHostOnlyNetworkDelete removes DHCP server and host-only network.
+func resourceHostOnlyNetworkDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+	network := expandHostOnlyNetwork(d)
+
+	if network.Type == "hostonlyif" {
+		dhcp, err := vb.DHCPInfo(network.DHCPNetworkName())
+		if err != nil {
+			return diag.Errorf("dhcpInfo failed: %s", err.Error())
+		}
+		if dhcp.NetworkName != "" {
+			if err := vb.RemoveDHCPServer(dhcp.NetworkName); err != nil {
+				return diag.Errorf("Removing DHCP server failed: %s", err.Error())
+			}
+		}
+	}
+
+	if err := pkg.RemoveHostOnlyNetwork(vb, network); err != nil {
+		return diag.Errorf("Removing host-only network failed: %s", err.Error())
+	}
+
+	return nil
+}
+
+// resourceHostOnlyNetworkImport adopts host-only interface or network by its name
+func resourceHostOnlyNetworkImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	vb := m.(*Client).VBox("")
+
+	for _, kind := range pkg.HostOnlyTypes() {
+		networks, err := pkg.HostOnlyNetworks(vb, kind)
+		if err != nil {
+			// hostonlynet is not available on every host
+			continue
+		}
+		if _, ok := networks[d.Id()]; ok {
+			if err := d.Set("type", kind); err != nil {
+				return nil, fmt.Errorf("didn't manage to set type: %s", err.Error())
+			}
+			return []*schema.ResourceData{d}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("host-only network %s not found", d.Id())
+}
+
+// expandHostOnlyNetwork returns host-only network from schema object.ResourceData
+func expandHostOnlyNetwork(d *schema.ResourceData) pkg.HostOnlyNetwork {
+	name := d.Id()
+	if name == "" {
+		name = d.Get("name").(string)
+	}
+
+	return pkg.HostOnlyNetwork{
+		Type:             d.Get("type").(string),
+		Name:             name,
+		IPv4Address:      d.Get("ipv4_address").(string),
+		IPv4Netmask:      d.Get("ipv4_netmask").(string),
+		IPv6Address:      d.Get("ipv6_address").(string),
+		IPv6PrefixLength: d.Get("ipv6_prefix_length").(int),
+		LowerIP:          d.Get("lower_ip").(string),
+		UpperIP:          d.Get("upper_ip").(string),
+	}
+}
+
+// expandHostOnlyDHCP returns DHCP server of host-only interface, ok is false if dhcp block is not set
+func expandHostOnlyDHCP(d *schema.ResourceData, network pkg.HostOnlyNetwork) (vbg.DHCPServer, bool) {
+	if _, ok := d.GetOk("dhcp"); !ok {
+		return vbg.DHCPServer{}, false
+	}
+
+	return vbg.DHCPServer{
+		NetworkName:    network.DHCPNetworkName(),
+		IPAddress:      d.Get("dhcp.0.server_ip").(string),
+		LowerIPAddress: d.Get("dhcp.0.lower_ip").(string),
+		UpperIPAddress: d.Get("dhcp.0.upper_ip").(string),
+		NetworkMask:    network.IPv4Netmask,
+		Enabled:        d.Get("dhcp.0.enabled").(bool),
+	}, true
+}
+
+// validateHostOnlyParams checks that attributes match type of host-only network
+func validateHostOnlyParams(d *schema.ResourceData) error {
+	var problems []string
+
+	if d.Get("type").(string) == "hostonlynet" {
+		if d.Get("name").(string) == "" {
+			problems = append(problems, "name is required for hostonlynet")
+		}
+		if d.Get("lower_ip").(string) == "" || d.Get("upper_ip").(string) == "" {
+			problems = append(problems, "lower_ip and upper_ip are required for hostonlynet")
+		}
+		if _, ok := d.GetOk("dhcp"); ok {
+			problems = append(problems, "dhcp is not supported by hostonlynet, VirtualBox serves addresses from lower_ip to upper_ip")
+		}
+		if isConfigured(d, "ipv4_address") || isConfigured(d, "ipv6_address") {
+			problems = append(problems, "ipv4_address and ipv6_address are not supported by hostonlynet")
+		}
+	} else {
+		if isConfigured(d, "name") {
+			problems = append(problems, "name of hostonlyif is chosen by VirtualBox and can't be set")
+		}
+		if d.Get("lower_ip").(string) != "" || d.Get("upper_ip").(string) != "" {
+			problems = append(problems, "lower_ip and upper_ip are only supported by hostonlynet, use dhcp block")
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	report := fmt.Sprintf("Host-only network: %v\n", d.Get("name").(string))
+	for i, problem := range problems {
+		report += fmt.Sprintf("%v) %s\n", i+1, problem)
+	}
+	return fmt.Errorf(report)
+}
@@ -0,0 +1,236 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/mixdone/terraform-provider-virtualbox/pkg"
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// resourceInternalNetwork returns schema for internal network resource.
+// internal network exists in VirtualBox only while it is used, so resource
+// owns its name and DHCP server and tracks adapters of VMs attached to it.
+func resourceInternalNetwork() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: resourceInternalNetworkCreate,
+		ReadContext:   resourceInternalNetworkRead,
+		UpdateContext: resourceInternalNetworkUpdate,
+		DeleteContext: resourceInternalNetworkDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description:  "Name of internal network, passed to network_adapter.name of virtualbox_server.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+			},
+
+			"dhcp": {
+				Description: "DHCP server bound to internal network.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"server_ip": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.IsIPv4Address,
+						},
+						"lower_ip": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.IsIPv4Address,
+						},
+						"upper_ip": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.IsIPv4Address,
+						},
+						"network_mask": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "255.255.255.0",
+							ValidateFunc: validation.IsIPv4Address,
+						},
+						"enabled": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  true,
+						},
+					},
+				},
+			},
+
+			"attached_nics": {
+				Description: "Network adapters of virtual machines attached to internal network.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"vm_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"vm_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"index": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// resourceInternalNetworkCreate adds DHCP server of internal network if it is set.
+func resourceInternalNetworkCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+	name := d.Get("name").(string)
+
+	if dhcp, ok := expandInternalNetworkDHCP(d, name); ok {
+		if _, err := vb.AddDHCPServer(dhcp); err != nil {
+			return diag.Errorf("Adding DHCP server failed: %s", err.Error())
+		}
+	}
+
+	d.SetId(name)
+
+	return resourceInternalNetworkRead(ctx, d, m)
+}
+
+// resourceInternalNetworkRead reads DHCP server of internal network and adapters attached to it.
+func resourceInternalNetworkRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	if err := d.Set("name", d.Id()); err != nil {
+		return diag.Errorf("Didn't manage to set name: %s", err.Error())
+	}
+
+	dhcp, err := vb.DHCPInfo(d.Id())
+	if err != nil {
+		return diag.Errorf("dhcpInfo failed: %s", err.Error())
+	}
+
+	servers := []map[string]interface{}{}
+	if dhcp.NetworkName != "" {
+		servers = append(servers, map[string]interface{}{
+			"server_ip":    dhcp.IPAddress,
+			"lower_ip":     dhcp.LowerIPAddress,
+			"upper_ip":     dhcp.UpperIPAddress,
+			"network_mask": dhcp.NetworkMask,
+			"enabled":      dhcp.Enabled,
+		})
+	}
+	if err := d.Set("dhcp", servers); err != nil {
+		return diag.Errorf("Didn't manage to set dhcp: %s", err.Error())
+	}
+
+	attachments, err := pkg.IntNetAttachments(vb, d.Id())
+	if err != nil {
+		return diag.Errorf("Listing attached adapters failed: %s", err.Error())
+	}
+
+	nics := make([]map[string]interface{}, 0, len(attachments))
+	for _, a := range attachments {
+		nics = append(nics, map[string]interface{}{
+			"vm_id":   a.VMID,
+			"vm_name": a.VMName,
+			"index":   a.Index,
+		})
+	}
+	if err := d.Set("attached_nics", nics); err != nil {
+		return diag.Errorf("Didn't manage to set attached_nics: %s", err.Error())
+	}
+
+	return nil
+}
+
+// resourceInternalNetworkUpdate adds, modifies or removes DHCP server of internal network.
+func resourceInternalNetworkUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	if d.HasChange("dhcp") {
+		old, err := vb.DHCPInfo(d.Id())
+		if err != nil {
+			return diag.Errorf("dhcpInfo failed: %s", err.Error())
+		}
+
+		dhcp, ok := expandInternalNetworkDHCP(d, d.Id())
+		switch {
+		case !ok && old.NetworkName != "":
+			if err := vb.RemoveDHCPServer(old.NetworkName); err != nil {
+				return diag.Errorf("Removing DHCP server failed: %s", err.Error())
+			}
+		case ok && old.NetworkName == "":
+			if _, err := vb.AddDHCPServer(dhcp); err != nil {
+				return diag.Errorf("Adding DHCP server failed: %s", err.Error())
+			}
+		case ok:
+			if err := vb.ModifyDHCPServer(dhcp, []string{"ip", "lowerip", "upperip", "netmask", "work"}); err != nil {
+				return diag.Errorf("Modify DHCP failed: %s", err.Error())
+			}
+		}
+	}
+
+	return resourceInternalNetworkRead(ctx, d, m)
+}
+
+// resourceInternalNetworkDelete removes DHCP server of internal network.
+// it fails while adapters of virtual machines are still attached to network.
+func resourceInternalNetworkDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	vb := m.(*Client).VBox("")
+
+	attachments, err := pkg.IntNetAttachments(vb, d.Id())
+	if err != nil {
+		return diag.Errorf("Listing attached adapters failed: %s", err.Error())
+	}
+	if len(attachments) > 0 {
+		nics := make([]string, 0, len(attachments))
+		for _, a := range attachments {
+			nics = append(nics, fmt.Sprintf("%s (adapter %d)", a.VMName, a.Index))
+		}
+		return diag.Errorf("Internal network %s is still used by: %s", d.Id(), strings.Join(nics, ", "))
+	}
+
+	dhcp, err := vb.DHCPInfo(d.Id())
+	if err != nil {
+		return diag.Errorf("dhcpInfo failed: %s", err.Error())
+	}
+	if dhcp.NetworkName != "" {
+		if err := vb.RemoveDHCPServer(dhcp.NetworkName); err != nil {
+			return diag.Errorf("Removing DHCP server failed: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// expandInternalNetworkDHCP returns DHCP server of internal network, ok is false if dhcp block is not set
+func expandInternalNetworkDHCP(d *schema.ResourceData, name string) (vbg.DHCPServer, bool) {
+	if _, ok := d.GetOk("dhcp"); !ok {
+		return vbg.DHCPServer{}, false
+	}
+
+	return vbg.DHCPServer{
+		NetworkName:    name,
+		IPAddress:      d.Get("dhcp.0.server_ip").(string),
+		LowerIPAddress: d.Get("dhcp.0.lower_ip").(string),
+		UpperIPAddress: d.Get("dhcp.0.upper_ip").(string),
+		NetworkMask:    d.Get("dhcp.0.network_mask").(string),
+		Enabled:        d.Get("dhcp.0.enabled").(bool),
+	}, true
+}
@@ -3,7 +3,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -18,6 +17,9 @@ func resourceNatNetwork() *schema.Resource {
 		UpdateContext: resourceNatNetworkUpdate,
 		DeleteContext: resourceNatNetworkDelete,
 		Exists:        resourceNatNetworkExists,
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -136,16 +138,8 @@ func resourceNatNetwork() *schema.Resource {
 
 // resourceNatNetworkCreate creates new NAT network.
 func resourceNatNetworkCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	// Retrieving home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return diag.Errorf("userhomedir failed: %s", err.Error())
-	}
-
 	// Initializing VirtualBox client
-	vb := vbg.NewVBox(vbg.Config{
-		BasePath: homeDir,
-	})
+	vb := m.(*Client).VBox("")
 
 	// Creating NAT network configuration
 	var natNet vbg.NatNetwork
@@ -220,16 +214,8 @@ func resourceNatNetworkCreate(ctx context.Context, d *schema.ResourceData, m int
 
 // resourceNatNetworkRead reads state of existing NAT network.
 func resourceNatNetworkRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	// Retrieving home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return diag.Errorf("userhomedir failed: %s", err.Error())
-	}
-
 	// Initializing VirtualBox client
-	vb := vbg.NewVBox(vbg.Config{
-		BasePath: homeDir,
-	})
+	vb := m.(*Client).VBox("")
 
 	// Retrieving list of NAT networks
 	natnets, err := vb.ListNatNets()
@@ -243,12 +229,16 @@ func resourceNatNetworkRead(ctx context.Context, d *schema.ResourceData, m inter
 
 	var necessaryNetwork *vbg.NatNetwork
 
-	for _, i := range natnets {
-		if i.NetName == id {
-			necessaryNetwork = &i
+	for i := range natnets {
+		if natnets[i].NetName == id {
+			necessaryNetwork = &natnets[i]
 		}
 	}
 
+	if necessaryNetwork == nil {
+		return diag.Errorf("NAT network %s not found", id)
+	}
+
 	// Setting resource data based on retrieved NAT network configuration
 	if err := d.Set("name", necessaryNetwork.NetName); err != nil {
 		return diag.Errorf("Didn't manage to set name: %s", err.Error())
@@ -317,16 +307,8 @@ func resourceNatNetworkRead(ctx context.Context, d *schema.ResourceData, m inter
 
 // resourceNatNetworkUpdate updates existing NAT network.
 func resourceNatNetworkUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	// Retrieving home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return diag.Errorf("userhomedir failed: %s", err.Error())
-	}
-
 	// Initializing VirtualBox client
-	vb := vbg.NewVBox(vbg.Config{
-		BasePath: homeDir,
-	})
+	vb := m.(*Client).VBox("")
 
 	// Retrieving list of NAT networks
 	natnets, err := vb.ListNatNets()
@@ -340,12 +322,16 @@ func resourceNatNetworkUpdate(ctx context.Context, d *schema.ResourceData, m int
 
 	var necessaryNetwork *vbg.NatNetwork
 
-	for _, i := range natnets {
-		if i.NetName == id {
-			necessaryNetwork = &i
+	for i := range natnets {
+		if natnets[i].NetName == id {
+			necessaryNetwork = &natnets[i]
 		}
 	}
 
+	if necessaryNetwork == nil {
+		return diag.Errorf("NAT network %s not found", id)
+	}
+
 	// Collecting parameters for update
 	parameters := []string{}
 
@@ -572,16 +558,8 @@ func resourceNatNetworkUpdate(ctx context.Context, d *schema.ResourceData, m int
 
 // resourceNatNetworkDelete deletes existing NAT network.
 func resourceNatNetworkDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	// Retrieving home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return diag.Errorf("userhomedir failed: %s", err.Error())
-	}
-
 	// Initializing VirtualBox client
-	vb := vbg.NewVBox(vbg.Config{
-		BasePath: homeDir,
-	})
+	vb := m.(*Client).VBox("")
 
 	// Retrieving list of NAT networks
 	natnets, err := vb.ListNatNets()
@@ -595,12 +573,16 @@ func resourceNatNetworkDelete(ctx context.Context, d *schema.ResourceData, m int
 
 	var necessaryNetwork *vbg.NatNetwork
 
-	for _, i := range natnets {
-		if i.NetName == id {
-			necessaryNetwork = &i
+	for i := range natnets {
+		if natnets[i].NetName == id {
+			necessaryNetwork = &natnets[i]
 		}
 	}
 
+	if necessaryNetwork == nil {
+		return diag.Errorf("NAT network %s not found", id)
+	}
+
 	// Stopping and removing NAT network
 	if err := vb.StopNatNet(necessaryNetwork); err != nil {
 		return diag.Errorf("Stopping NAT network failed: %s", err.Error())
@@ -614,14 +596,7 @@ func resourceNatNetworkDelete(ctx context.Context, d *schema.ResourceData, m int
 }
 
 func resourceNatNetworkExists(d *schema.ResourceData, m interface{}) (bool, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return false, fmt.Errorf("userhomedir failed: %s", err.Error())
-	}
-
-	vb := vbg.NewVBox(vbg.Config{
-		BasePath: homeDir,
-	})
+	vb := m.(*Client).VBox("")
 
 	natnets, err := vb.ListNatNets()
 	if err != nil {
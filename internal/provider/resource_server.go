@@ -2,13 +2,21 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/mixdone/terraform-provider-virtualbox/pkg"
 	vbg "github.com/mixdone/virtualbox-go"
 	"github.com/sirupsen/logrus"
@@ -23,6 +31,19 @@ func resourceVM() *schema.Resource {
 		UpdateContext: resourceVirtualBoxUpdate,
 		DeleteContext: resourceVirtualBoxDelete,
 		Exists:        resourceVirtualBoxExists,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceVirtualBoxImport,
+		},
+
+		CustomizeDiff: customdiff.All(
+			customdiff.ValidateChange("disk_size", func(ctx context.Context, old, new, m interface{}) error {
+				if new.(int) != 0 && old.(int) > new.(int) {
+					return fmt.Errorf("disk can not be shrunk from %d MB to %d MB", old.(int), new.(int))
+				}
+				return nil
+			}),
+			validateBridgedAdapters,
+		),
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -32,25 +53,31 @@ func resourceVM() *schema.Resource {
 			},
 
 			"basedir": {
-				Description: "The folder in which the virtual machine data will be located.",
+				Description: "The folder in which the virtual machine data will be located. Defaults to default_basedir of the provider.",
 				Type:        schema.TypeString,
 				Optional:    true,
-				Default:     "VMs",
+				Computed:    true,
 				ForceNew:    true,
 			},
 
+			"basedir_created": {
+				Description: "Whether basedir was created by provider. Only such basedir is removed on destroy.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+
 			"memory": {
-				Description: "RAW allocated for machine.",
+				Description: "RAW allocated for machine. Defaults to 128, imported appliance or clone keeps the memory of its source.",
 				Type:        schema.TypeInt,
 				Optional:    true,
-				Default:     128,
+				Computed:    true,
 			},
 
 			"disk_size": {
-				Description: "VDI size in MB.",
+				Description: "VDI size in MB. Defaults to 15000, growing it resizes the disk.",
 				Type:        schema.TypeInt,
 				Optional:    true,
-				Default:     15000,
+				Computed:    true,
 			},
 
 			"group": {
@@ -61,10 +88,68 @@ func resourceVM() *schema.Resource {
 			},
 
 			"cpus": {
-				Description: "Amount of CPUs.",
+				Description: "Amount of CPUs. Defaults to 2, imported appliance or clone keeps the amount of CPUs of its source.",
 				Type:        schema.TypeInt,
 				Optional:    true,
-				Default:     2,
+				Computed:    true,
+			},
+
+			"cpu_execution_cap": {
+				Description:  "Percentage of host CPU time a virtual CPU can use.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      100,
+				ValidateFunc: validation.IntBetween(1, 100),
+			},
+
+			"nested_virtualization": {
+				Description: "Pass hardware virtualization to guest.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+
+			"pae": {
+				Description: "Enable PAE/NX, by default depends on os_id.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+			},
+
+			"long_mode": {
+				Description: "Enable 64-bit long mode, by default depends on os_id.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+			},
+
+			"hpet": {
+				Description: "Enable High Precision Event Timer.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+			},
+
+			"paravirt_provider": {
+				Description:  "none | default | legacy | minimal | hyperv | kvm",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(pkg.ParavirtProviders(), false),
+			},
+
+			"nested_paging": {
+				Description: "Enable nested paging of hardware virtualization.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
+			},
+
+			"large_pages": {
+				Description: "Use large pages of host for nested paging.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Computed:    true,
 			},
 
 			"status": {
@@ -74,6 +159,172 @@ func resourceVM() *schema.Resource {
 				Default:     "poweroff",
 			},
 
+			"firmware": {
+				Description:  "bios | efi | efi64",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "bios",
+				ValidateFunc: validation.StringInSlice(pkg.Firmwares(), false),
+			},
+
+			"boot_order": {
+				Description: "Boot devices in order of priority (none | floppy | dvd | disk | net).",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Computed:    true,
+				MaxItems:    4,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice(pkg.BootDevices(), false),
+				},
+			},
+
+			"chipset": {
+				Description:  "piix3 | ich9",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "piix3",
+				ValidateFunc: validation.StringInSlice(pkg.Chipsets(), false),
+			},
+
+			"secure_boot": {
+				Description: "Enable UEFI secure boot, requires efi firmware.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+
+			"tpm_type": {
+				Description:  "none | 1.2 | 2.0",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "none",
+				ValidateFunc: validation.StringInSlice(pkg.TPMTypes(), false),
+			},
+
+			"rtc_use_utc": {
+				Description: "Real-time clock of Virtual Machine is in UTC.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+
+			"ioapic": {
+				Description: "Enable I/O APIC, required for more than one CPU and 64-bit guests.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
+
+			"video_memory": {
+				Description: "Video memory in MB, by default depends on os_id.",
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+			},
+
+			"graphics_controller": {
+				Description:  "vmsvga | vboxsvga | vboxvga, by default depends on os_id.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice(pkg.GraphicsControllers(), false),
+			},
+
+			"accelerate_3d": {
+				Description: "Enables 3D acceleration of graphics controller, requires guest additions.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+
+			"monitor_count": {
+				Description:  "Number of virtual monitors, 1 - 64.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      1,
+				ValidateFunc: validation.IntBetween(1, 64),
+			},
+
+			"vrde": {
+				Description: "Remote desktop server of Virtual Machine, requires VirtualBox Extension Pack.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  true,
+						},
+						"port": {
+							Description: "Port, list or range of ports to listen on, e.g. 5000-5050.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "3389",
+						},
+						"address": {
+							Description: "Address to listen on, all addresses if empty.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+						},
+						"auth_type": {
+							Description:  "null | external | guest",
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "null",
+							ValidateFunc: validation.StringInSlice(pkg.VRDEAuthTypes(), false),
+						},
+					},
+				},
+			},
+
+			"vrde_port": {
+				Description: "Port remote desktop server listens on while Virtual Machine is running.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+
+			"wait_for_guest": {
+				Description: "Wait for guest additions to report after Virtual Machine is started.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"timeout": {
+							Description:  "How long to wait, e.g. 5m.",
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "5m",
+							ValidateFunc: validateDuration,
+						},
+						"property": {
+							Description: "Guest property which guest sets when it is ready.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "/VirtualBox/GuestInfo/Net/0/V4/IP",
+						},
+					},
+				},
+			},
+
+			"ipv4_addresses": {
+				Description: "IPv4 addresses reported by guest additions.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"ipv6_addresses": {
+				Description: "IPv6 addresses reported by guest additions.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
 			"image": {
 				Description: "Path to image that is located on the host.",
 				Type:        schema.TypeString,
@@ -88,11 +339,125 @@ func resourceVM() *schema.Resource {
 				ForceNew:    true,
 			},
 
+			"url_checksum": {
+				Description:  "Checksum of file downloaded from url: sha256:<hex> | sha512:<hex> | md5:<hex> | file:<url of checksums file>.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				RequiredWith: []string{"url"},
+				ValidateFunc: validation.StringMatch(regexp.MustCompile(`^(sha256|sha512|md5|file):.+`), "must look like <algorithm>:<hash> or file:<url>"),
+			},
+
+			"archive_entry": {
+				Description:  "Path of disk or ISO inside archive downloaded from url. By default the only disk or ISO in archive is used.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				RequiredWith: []string{"url"},
+			},
+
 			"disk": {
 				Type:     schema.TypeString,
 				Optional: true,
 			},
 
+			"clone_from": {
+				Description:   "Virtual Machine to clone instead of loading image, url or disk.",
+				Type:          schema.TypeList,
+				Optional:      true,
+				ForceNew:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{"image", "url", "disk"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"vm": {
+							Description: "Name or UUID of source Virtual Machine.",
+							Type:        schema.TypeString,
+							Required:    true,
+						},
+						"snapshot": {
+							Description: "Snapshot of source Virtual Machine to clone, current state if empty.",
+							Type:        schema.TypeString,
+							Optional:    true,
+						},
+						"linked": {
+							Description: "Create linked clone sharing disks with the snapshot, requires snapshot.",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+					},
+				},
+			},
+
+			"storage": {
+				Description: "Data disks attached to the virtual machine. Disk is identified by its controller, port and device.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"size": {
+							Description: "Disk size in MB. Required unless disk_id is set.",
+							Type:        schema.TypeInt,
+							Optional:    true,
+							Computed:    true,
+						},
+						"disk_id": {
+							Description: "ID of virtualbox_disk to attach instead of creating new disk.",
+							Type:        schema.TypeString,
+							Optional:    true,
+						},
+						"format": {
+							Description: "vdi | vmdk | vhd",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "vdi",
+						},
+						"controller": {
+							Description: "sata | scsi | nvme | virtio-scsi | ide",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "sata",
+						},
+						"port": {
+							Type:     schema.TypeInt,
+							Required: true,
+						},
+						"device": {
+							Type:     schema.TypeInt,
+							Optional: true,
+							Default:  0,
+						},
+						"non_rotational": {
+							Description: "Report disk as SSD to the guest.",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+						"hot_pluggable": {
+							Description: "Allow hot-plugging of the disk, sata only.",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+						"discard": {
+							Description: "Pass TRIM requests from the guest to the disk image.",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+						"path": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"uuid": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+
 			"network_adapter": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -122,6 +487,87 @@ func resourceVM() *schema.Resource {
 							Optional: true,
 							Default:  false,
 						},
+						"mac_address": {
+							Description:      "MAC address, e.g 08:00:27:a1:b2:c3. Assigned by VirtualBox when unset.",
+							Type:             schema.TypeString,
+							Optional:         true,
+							Computed:         true,
+							ValidateFunc:     validateMAC,
+							DiffSuppressFunc: suppressEqualMAC,
+						},
+						"promiscuous_mode": {
+							Description:  "deny | allow-vms | allow-all",
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "deny",
+							ValidateFunc: validation.StringInSlice(pkg.PromiscuousModes(), false),
+						},
+						"boot_priority": {
+							Description:  "Priority of network boot, 1 is the highest, 0 is the lowest.",
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      0,
+							ValidateFunc: validation.IntBetween(0, 4),
+						},
+						"bandwidth_group": {
+							Description: "Bandwidth group of VM limiting traffic of adapter.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+						},
+						"trace_file": {
+							Description: "File on host to which traffic of adapter is written in pcap format.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+						},
+						"nat_network": {
+							Description:  "Network of NAT engine in CIDR notation, e.g 192.168.100.0/24. Defaults to 10.0.N.0/24, nat mode only.",
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "",
+							ValidateFunc: validation.Any(validation.StringIsEmpty, validation.IsCIDR),
+						},
+						"dns_proxy": {
+							Description: "NAT engine proxies DNS requests to DNS servers of host, nat mode only.",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+						"dns_host_resolver": {
+							Description: "NAT engine resolves DNS requests with resolver of host, nat mode only.",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+						"alias_mode": {
+							Description: "Flags of NAT alias mode (log, proxyonly, sameports), nat mode only.",
+							Type:        schema.TypeSet,
+							Optional:    true,
+							Elem: &schema.Schema{
+								Type:         schema.TypeString,
+								ValidateFunc: validation.StringInSlice(pkg.AliasModes(), false),
+							},
+						},
+						"tftp_prefix": {
+							Description: "Folder of built-in TFTP server of NAT engine used for network boot, nat mode only.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+						},
+						"tftp_file": {
+							Description: "Boot file served by built-in TFTP server of NAT engine, nat mode only.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+						},
+						"bind_ip": {
+							Description:  "Host address to which NAT engine binds outgoing connections, nat mode only.",
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "",
+							ValidateFunc: validation.Any(validation.StringIsEmpty, validation.IsIPAddress),
+						},
 						"port_forwarding": {
 							Type:     schema.TypeList,
 							Optional: true,
@@ -162,18 +608,82 @@ func resourceVM() *schema.Resource {
 				},
 			},
 
+			"shared_folder": {
+				Description: "Host folders shared with Virtual Machine.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Description: "Name of shared folder in guest.",
+							Type:        schema.TypeString,
+							Required:    true,
+						},
+						"host_path": {
+							Description: "Absolute path of folder on host.",
+							Type:        schema.TypeString,
+							Required:    true,
+						},
+						"read_only": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  false,
+						},
+						"auto_mount": {
+							Description: "Mount folder automatically by guest additions.",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+						"mount_point": {
+							Description: "Where guest additions mount folder, e.g. /src.",
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+						},
+						"transient": {
+							Description: "Share folder only while Virtual Machine is running, requires status running.",
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+						},
+					},
+				},
+			},
+
 			"user_data": {
-				Description: "Userdata for virtual machine.",
+				Description: "Userdata for virtual machine, passed to cloud-init with NoCloud seed image.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+			},
+
+			"meta_data": {
+				Description: "Metadata for cloud-init, by default VM UUID is instance-id and VM name is hostname.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+			},
+
+			"network_config": {
+				Description: "Network configuration for cloud-init.",
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "",
 			},
 
+			"guest_properties": {
+				Description: "Guest properties of Virtual Machine set without flags, other properties are left as is.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
 			"os_id": {
-				Description: "Specifies the guest OS to run in the VM.",
+				Description: "Specifies the guest OS to run in the VM. Defaults to Linux_64, imported appliance or clone keeps the OS type of its source.",
 				Type:        schema.TypeString,
 				Optional:    true,
-				Default:     "Linux_64",
+				Computed:    true,
 			},
 
 			"drag_and_drop": {
@@ -235,7 +745,10 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 	vmConf.Name = d.Get("name").(string)
 	vmConf.CPUs = d.Get("cpus").(int)
 	vmConf.Memory = d.Get("memory").(int)
-	vmConf.DiskSize = int64(d.Get("disk_size").(int))
+	vmConf.DiskSize = 15000
+	if diskSize, ok := d.GetOk("disk_size"); ok {
+		vmConf.DiskSize = int64(diskSize.(int))
+	}
 	vmConf.OS_id = d.Get("os_id").(string)
 	vmConf.Group = d.Get("group").(string)
 	vmConf.DragAndDrop = d.Get("drag_and_drop").(string)
@@ -254,16 +767,26 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 	}
 
 	// Making new folders for VirtualMachine data
-	homedir, err := os.UserHomeDir()
-	if err != nil {
-		return diag.Errorf("userhomedir failed: %s", err.Error())
+	client := m.(*Client)
+	basedir := client.DefaultBasedir
+	if val, ok := d.GetOk("basedir"); ok {
+		basedir = val.(string)
+	}
+	if err := d.Set("basedir", basedir); err != nil {
+		return diag.Errorf("Didn't manage to set basedir: %s", err.Error())
 	}
 
-	machinesDir := filepath.Join(homedir, d.Get("basedir").(string))
+	machinesDir := client.MachinePath(basedir)
 	installedData := filepath.Join(machinesDir, "InstalledData")
 
 	vmConf.Dirname = machinesDir
 
+	// Basedir which existed before, e.g home or folder of other tools, must survive destroy
+	_, statErr := os.Stat(machinesDir)
+	if err := d.Set("basedir_created", os.IsNotExist(statErr)); err != nil {
+		return diag.Errorf("Didn't manage to set basedir_created: %s", err.Error())
+	}
+
 	if err := os.MkdirAll(machinesDir, 0740); err != nil {
 		return diag.Errorf("Creation VirtualMachines foldier failed: %s", err.Error())
 	}
@@ -273,6 +796,9 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 
 	var ltype pkg.LoadingType
 
+	// Releasing cached image once VM does not read it anymore
+	releaseImage := func() {}
+
 	// Obtaining the image
 	im, ok := d.GetOk("image")
 	image := im.(string)
@@ -286,28 +812,53 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 			}
 			image = disk.(string)
 		} else {
-			filename, err := pkg.FileDownload(url.(string), homedir)
+			// Cache entry stays locked while VM uses cached file, so that clones and conversions
+			// of VMs created from the same url in parallel don't race and entry is not evicted
+			filename, release, err := client.ImageCache.Fetch(url.(string), d.Get("url_checksum").(string))
 			if err != nil {
 				return diag.Errorf("File dowload failed: %s", err.Error())
 			}
+			defer release()
+			releaseImage = release
 
-			if filepath.Ext(filepath.Base(filename)) == ".vdi" {
-				image = filename
-			} else if filepath.Ext(filepath.Base(filename)) == ".vhd" {
-				image = filename
-			} else if filepath.Ext(filepath.Base(filename)) == ".vmdk" {
+			// qcow2 and raw images are converted to VDI once and cached next to downloaded file
+			filename, err = pkg.ConvertImage(filename)
+			if err != nil {
+				return diag.Errorf("Image conversion failed: %s", err.Error())
+			}
+
+			// Cached disk is shared by VMs, so every VM gets its own copy
+			ext := filepath.Ext(filepath.Base(filename))
+			if ext == ".vdi" || ext == ".vhd" || ext == ".vmdk" {
+				image = filepath.Join(machinesDir, vmConf.Name+ext)
+				if err := pkg.CopyDisk(client.VBox(basedir), filename, image); err != nil {
+					return diag.Errorf("Copying disk failed: %s", err.Error())
+				}
+				release()
+			} else if filepath.Ext(filepath.Base(filename)) == ".ova" || filepath.Ext(filepath.Base(filename)) == ".ovf" {
 				image = filename
 			} else if filepath.Ext(filepath.Base(filename)) != ".iso" {
-				imagePath, err := pkg.UnpackImage(filename, installedData)
+				// Every VM unpacks archive into its own folder
+				imagePath, err := pkg.UnpackImage(filename, filepath.Join(installedData, vmConf.Name), d.Get("archive_entry").(string))
 				if err != nil {
 					return diag.Errorf("File unpaking failed: %s", err.Error())
 				}
-				basePath := filepath.Base(imagePath)
-				basePath = basePath[:len(basePath)-len(filepath.Ext(basePath))] + vmConf.Name + filepath.Ext(basePath)
-				image = filepath.Join(imagePath[:len(imagePath)-len(filepath.Base(imagePath))], basePath)
-				os.Rename(imagePath, image)
+				image, err = pkg.ConvertImage(imagePath)
+				if err != nil {
+					return diag.Errorf("Image conversion failed: %s", err.Error())
+				}
+				release()
 			} else {
-				image = filename
+				// Cached ISO can be evicted while it is attached, so every VM gets its own copy
+				image = filepath.Join(installedData, vmConf.Name, filepath.Base(filename))
+				if err := pkg.CopyImage(filename, image); err != nil {
+					return diag.Errorf("Copying image failed: %s", err.Error())
+				}
+				release()
+			}
+
+			if filepath.Ext(image) == ".iso" {
+				ltype = 1
 			}
 		}
 	} else {
@@ -318,6 +869,35 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 		}
 	}
 
+	// Importing appliance
+	if ext := filepath.Ext(image); ext == ".ova" || ext == ".ovf" {
+		ltype = 4
+	}
+
+	// Cloning VM
+	if _, ok := d.GetOk("clone_from"); ok {
+		ltype = 3
+		vmConf.Clone = pkg.CloneSource{
+			VM:       d.Get("clone_from.0.vm").(string),
+			Snapshot: d.Get("clone_from.0.snapshot").(string),
+			Linked:   d.Get("clone_from.0.linked").(bool),
+		}
+	}
+
+	// Appliance and clone keep CPUs, memory and OS type of their source unless they are set,
+	// other VMs get defaults, zero values are not applied by CreateVM
+	if ltype != 3 && ltype != 4 {
+		if !isConfigured(d, "cpus") {
+			vmConf.CPUs = 2
+		}
+		if !isConfigured(d, "memory") {
+			vmConf.Memory = 128
+		}
+		if !isConfigured(d, "os_id") {
+			vmConf.OS_id = "Linux_64"
+		}
+	}
+
 	var NICs [20]vbg.NIC
 
 	for i, nic := range NICs {
@@ -379,17 +959,24 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 	// Applying network adapter settings to VMConfig
 	vmConf.NICs = NICs[:]
 
+	// Applying firmware, boot order and chipset to VMConfig
+	vmConf.Platform = expandPlatform(d)
+
+	// Applying data disks to VMConfig
+	vmConf.Storage = expandStorage(d.Get("storage").([]interface{}), vmConf.Name, machinesDir)
+
 	// Creating VM with specified parametrs
 	vm, err := pkg.CreateVM(vmConf)
 	if err != nil {
 		return diag.Errorf("Creation VM failed: %s", err.Error())
 	}
+	releaseImage()
 
 	// Setting the VM id for Terraform
 	d.SetId(vm.UUIDOrName())
 
 	// Getting information about VM and managing it
-	vb := vbg.NewVBox(vbg.Config{BasePath: filepath.Join(homedir, d.Get("basedir").(string))})
+	vb := client.VBox(basedir)
 
 	// Updating status of virtual machine
 	vm, err = vb.VMInfo(d.Id())
@@ -398,21 +985,70 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 		return diag.Errorf("VMInfo failed: %s", err.Error())
 	}
 
-	status := d.Get("status").(string)
-
-	if len(rule) > 0 {
-		if err := vb.AddALlPortForw(vm, rule); err != nil {
-			return diag.Errorf("Unable to set all port forwardings: %s", err.Error())
+	// Disk loaded from existing image keeps its own size unless disk_size is set
+	var diags diag.Diagnostics
+	if diskSize, ok := d.GetOk("disk_size"); ok && (ltype == 0 || ltype == 3 || ltype == 4) {
+		if err := resizeVMDisk(vb, vm, int64(diskSize.(int))); errors.Is(err, errNoVMDisk) {
+			diags = append(diags, noVMDiskWarning(vm))
+		} else if err != nil {
+			return diag.Errorf("Resizing disk failed: %s", err.Error())
 		}
 	}
 
-	vm.Spec.DragAndDrop = vmConf.DragAndDrop
-	vm.Spec.Clipboard = vmConf.Clipboard
-
-	if vmConf.DragAndDrop != "disabled" || vmConf.Clipboard != "disabled" {
-
-		if _, err := vb.ControlVM(vm, "draganddrop"); err != nil {
-			return diag.Errorf("Unable to set draganddrop VM: %s", err.Error())
+	// Attaching cloud-init seed image
+	if seed := cloudInitSeed(d); !seed.Empty() {
+		if err := pkg.AttachSeed(vb, vm, seed); err != nil {
+			return diag.Errorf("Attaching cloud-init seed failed: %s", err.Error())
+		}
+	}
+
+	// Setting CPU features, unset ones keep defaults of VirtualBox
+	info, err := pkg.VMInfoMap(vb, vm.UUID)
+	if err != nil {
+		return diag.Errorf("VMInfo failed: %s", err.Error())
+	}
+	current := pkg.ReadCPUFeatures(info)
+	if err := pkg.SetCPUFeatures(vb, vm, expandCPUFeatures(d, current), current); err != nil {
+		return diag.Errorf("Setting CPU features failed: %s", err.Error())
+	}
+
+	// Setting display and remote desktop
+	display, _ := pkg.ReadDisplay(info)
+	if err := pkg.SetDisplay(vb, vm, expandDisplay(d, display), display); err != nil {
+		return diag.Errorf("Setting display failed: %s", err.Error())
+	}
+
+	// Setting MAC addresses and other settings of network adapters
+	if err := updateNICSettings(d, vb, vm); err != nil {
+		return diag.Errorf("Setting network adapters failed: %s", err.Error())
+	}
+
+	// Sharing folders, transient ones are shared after start
+	folders := expandSharedFolders(d.Get("shared_folder").([]interface{}))
+	if err := updateSharedFolders(vb, vm, nil, folders); err != nil {
+		return diag.Errorf("Sharing folders failed: %s", err.Error())
+	}
+
+	// Setting guest properties
+	if err := updateGuestProperties(vb, vm, map[string]interface{}{}, d.Get("guest_properties").(map[string]interface{})); err != nil {
+		return diag.Errorf("Setting guest properties failed: %s", err.Error())
+	}
+
+	status := d.Get("status").(string)
+
+	if len(rule) > 0 {
+		if err := vb.AddALlPortForw(vm, rule); err != nil {
+			return diag.Errorf("Unable to set all port forwardings: %s", err.Error())
+		}
+	}
+
+	vm.Spec.DragAndDrop = vmConf.DragAndDrop
+	vm.Spec.Clipboard = vmConf.Clipboard
+
+	if vmConf.DragAndDrop != "disabled" || vmConf.Clipboard != "disabled" {
+
+		if _, err := vb.ControlVM(vm, "draganddrop"); err != nil {
+			return diag.Errorf("Unable to set draganddrop VM: %s", err.Error())
 		}
 
 		if _, err := vb.ControlVM(vm, "clipboard mode"); err != nil {
@@ -421,6 +1057,9 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 	}
 
 	if status != "poweroff" {
+		if err := resetGuestProperty(d, vb, vm); err != nil {
+			return diag.Errorf("Resetting guest property failed: %s", err.Error())
+		}
 		if _, err := vb.ControlVM(vm, status); err != nil {
 			return diag.Errorf("Unable to set state VM: %s", err.Error())
 		}
@@ -430,12 +1069,21 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 		}
 	}
 
-	userData := d.Get("user_data").(string)
-	if userData != "" {
-		vb.SetCloudData("user_data", userData)
+	// Sharing transient folders
+	if status == "running" {
+		if err := addTransientFolders(vb, vm, folders); err != nil {
+			return diag.Errorf("Sharing folders failed: %s", err.Error())
+		}
+	}
+
+	// Waiting for guest, so that provisioners do not race the boot
+	if status == "running" {
+		if err := waitForGuest(ctx, d, vb, vm); err != nil {
+			return diag.Errorf("Waiting for guest failed: %s", err.Error())
+		}
 	}
 
-	return resourceVirtualBoxRead(ctx, d, m)
+	return append(diags, resourceVirtualBoxRead(ctx, d, m)...)
 }
 
 // resourceVirtualBoxRead reads information about virtual machine
@@ -444,14 +1092,7 @@ func resourceVirtualBoxCreate(ctx context.Context, d *schema.ResourceData, m int
 func resourceVirtualBoxRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 
 	// Getting Machine by id
-	homedir, err := os.UserHomeDir()
-	if err != nil {
-		return diag.Errorf("userhomedir failed: %s", err.Error())
-	}
-
-	// Creating basic path for VirtualBox
-	basePath := filepath.Join(homedir, d.Get("basedir").(string))
-	vb := vbg.NewVBox(vbg.Config{BasePath: basePath})
+	vb := m.(*Client).VBox(d.Get("basedir").(string))
 	vm, err := vb.VMInfo(d.Id())
 
 	if err != nil {
@@ -459,20 +1100,6 @@ func resourceVirtualBoxRead(ctx context.Context, d *schema.ResourceData, m inter
 		return diag.Errorf("VMInfo failed: %s", err.Error())
 	}
 
-	val := d.Get("user_data").(string)
-	if val != "" {
-		userData, err := vb.GetCloudData("user_data")
-		if err != nil {
-			return diag.Errorf("Failed to get cloud-config: %v", err.Error())
-		}
-		if userData != nil && *userData != "" {
-			err = d.Set("user_data", *userData)
-			if err != nil {
-				return diag.Errorf("Failed to set cloud-config: %v", err.Error())
-			}
-		}
-	}
-
 	if err := d.Set("drag_and_drop", vm.Spec.DragAndDrop); err != nil {
 		return diag.Errorf("Didn't manage to set drag and drop: %s", err.Error())
 	}
@@ -481,6 +1108,46 @@ func resourceVirtualBoxRead(ctx context.Context, d *schema.ResourceData, m inter
 		return diag.Errorf("Didn't manage to set clipboard: %s", err.Error())
 	}
 
+	info, err := pkg.VMInfoMap(vb, vm.UUID)
+	if err != nil {
+		return diag.Errorf("VMInfo failed: %s", err.Error())
+	}
+
+	// Set group of Machine for Terraform
+	group := info["groups"]
+	if group == "/" {
+		group = ""
+	}
+	if err := d.Set("group", group); err != nil {
+		return diag.Errorf("Didn't manage to set group: %s", err.Error())
+	}
+
+	// Set guest OS of Machine for Terraform
+	osID, err := osTypeID(vb, info["ostype"])
+	if err != nil {
+		return diag.Errorf("Getting OS types failed: %s", err.Error())
+	}
+	if osID != "" {
+		if err := d.Set("os_id", osID); err != nil {
+			return diag.Errorf("Didn't manage to set os_id: %s", err.Error())
+		}
+	}
+
+	// Set CPU features for Terraform
+	if err := setCPUFeatures(d, pkg.ReadCPUFeatures(info)); err != nil {
+		return diag.Errorf("Didn't manage to set CPU features: %s", err.Error())
+	}
+
+	// Set display and remote desktop for Terraform
+	if err := setDisplay(d, info); err != nil {
+		return diag.Errorf("Didn't manage to set display: %s", err.Error())
+	}
+
+	// Set firmware, boot order and chipset for Terraform
+	if err := setPlatform(d, pkg.ReadPlatform(info)); err != nil {
+		return diag.Errorf("Didn't manage to set platform: %s", err.Error())
+	}
+
 	// Set state of Machine for Terraform
 	if err := setState(d, vm); err != nil {
 		return diag.Errorf("Didn't manage to set VMState: %s", err.Error())
@@ -502,15 +1169,41 @@ func resourceVirtualBoxRead(ctx context.Context, d *schema.ResourceData, m inter
 	}
 
 	// Set network for Terraform
-	if err := setNetwork(d, vm); err != nil {
+	pkg.FillNICNames(vm, info)
+	if err := setNetwork(d, vb, vm); err != nil {
 		return diag.Errorf("Didn't manage to set Network: %s", err.Error())
 	}
 
+	// Set addresses reported by guest for Terraform
+	if err := setGuestAddresses(d, vb, vm); err != nil {
+		return diag.Errorf("Didn't manage to set guest addresses: %s", err.Error())
+	}
+
+	// Set shared folders for Terraform
+	if err := setSharedFolders(d, vb, vm); err != nil {
+		return diag.Errorf("Didn't manage to set shared_folder: %s", err.Error())
+	}
+
+	// Set guest properties for Terraform
+	if err := setGuestProperties(d, vb, vm); err != nil {
+		return diag.Errorf("Didn't manage to set guest_properties: %s", err.Error())
+	}
+
 	// Set snapshots for Terraform
 	if err := setSnapshots(d, vm); err != nil {
 		return diag.Errorf("Didn't manage to set snapshots: %s", err.Error())
 	}
 
+	// Set size of VM disk for Terraform
+	if err := setDiskSize(d, vb, info); err != nil {
+		return diag.Errorf("Didn't manage to set disk_size: %s", err.Error())
+	}
+
+	// Set data disks for Terraform
+	if err := setStorage(d, vb, info); err != nil {
+		return diag.Errorf("Didn't manage to set storage: %s", err.Error())
+	}
+
 	// Set basedir VM for Terraform
 	if err := d.Set("basedir", d.Get("basedir").(string)); err != nil {
 		return diag.Errorf("Didn't manage to set basedir: %s", err.Error())
@@ -551,8 +1244,7 @@ func resourceVirtualBoxUpdate(ctx context.Context, d *schema.ResourceData, m int
 	}
 
 	// Getting VM by id
-	homedir, _ := os.UserHomeDir()
-	vb := vbg.NewVBox(vbg.Config{BasePath: filepath.Join(homedir, d.Get("basedir").(string))})
+	vb := m.(*Client).VBox(d.Get("basedir").(string))
 	vm, err := vb.VMInfo(d.Id())
 
 	// Array of parametrs
@@ -735,12 +1427,101 @@ func resourceVirtualBoxUpdate(ctx context.Context, d *schema.ResourceData, m int
 		vm.Spec.Clipboard = clipboardMode
 	}
 
+	// Setting new CPU features
+	info, err := pkg.VMInfoMap(vb, vm.UUIDOrName())
+	if err != nil {
+		return diag.Errorf("VMInfo failed: %s", err.Error())
+	}
+	actualFeatures := pkg.ReadCPUFeatures(info)
+	if err := pkg.SetCPUFeatures(vb, vm, expandCPUFeatures(d, actualFeatures), actualFeatures); err != nil {
+		return diag.Errorf("Setting CPU features failed: %s", err.Error())
+	}
+
+	// Setting new display and remote desktop
+	actualDisplay, _ := pkg.ReadDisplay(info)
+	if err := pkg.SetDisplay(vb, vm, expandDisplay(d, actualDisplay), actualDisplay); err != nil {
+		return diag.Errorf("Setting display failed: %s", err.Error())
+	}
+
+	// Updating firmware, boot order and chipset
+	if d.HasChanges("firmware", "boot_order", "chipset", "secure_boot", "tpm_type", "rtc_use_utc", "ioapic") {
+		if err := pkg.SetPlatform(vb, vm, expandPlatform(d)); err != nil {
+			return diag.Errorf("Updating platform failed: %s", err.Error())
+		}
+	}
+
 	// Modify VM
 	if len(parameters) != 0 {
 		err = vb.ModifyVM(vm, parameters)
 		if err != nil {
 			return diag.Errorf("ModifyVM failed: %s", err.Error())
 		}
+
+		if needAppendNetwork {
+			if err := pkg.SetHostOnlyNets(vb, vm); err != nil {
+				return diag.Errorf("ModifyVM failed: %s", err.Error())
+			}
+		}
+	}
+
+	// Updating MAC addresses and other settings of network adapters
+	if d.HasChange("network_adapter") {
+		if err := updateNICSettings(d, vb, vm); err != nil {
+			return diag.Errorf("Updating network adapters failed: %s", err.Error())
+		}
+	}
+
+	// Growing VM disk
+	var diags diag.Diagnostics
+	if d.HasChange("disk_size") {
+		if err := resizeVMDisk(vb, vm, int64(d.Get("disk_size").(int))); errors.Is(err, errNoVMDisk) {
+			diags = append(diags, noVMDiskWarning(vm))
+		} else if err != nil {
+			return diag.Errorf("Resizing disk failed: %s", err.Error())
+		}
+	}
+
+	// Updating data disks
+	if d.HasChange("storage") {
+		oldStorage, newStorage := d.GetChange("storage")
+		oldName, _ := d.GetChange("name")
+		machinesDir := m.(*Client).MachinePath(d.Get("basedir").(string))
+		if err := updateStorage(vb, vm,
+			expandStoredStorage(oldStorage.([]interface{}), oldName.(string), machinesDir),
+			expandStorage(newStorage.([]interface{}), vm.Spec.Name, machinesDir)); err != nil {
+			return diag.Errorf("Updating storage failed: %s", err.Error())
+		}
+	}
+
+	// Regenerating cloud-init seed image, default meta-data depends on name
+	seed := cloudInitSeed(d)
+	if d.HasChanges("user_data", "meta_data", "network_config") || (d.HasChange("name") && !seed.Empty()) {
+		if err := pkg.DetachSeed(vb, vm); err != nil {
+			return diag.Errorf("Detaching cloud-init seed failed: %s", err.Error())
+		}
+		if !seed.Empty() {
+			if err := pkg.AttachSeed(vb, vm, seed); err != nil {
+				return diag.Errorf("Attaching cloud-init seed failed: %s", err.Error())
+			}
+		}
+	}
+
+	// Updating shared folders, transient ones are gone after poweroff and are shared after start
+	if d.HasChange("shared_folder") {
+		oldFolders, newFolders := d.GetChange("shared_folder")
+		if err := updateSharedFolders(vb, vm,
+			expandSharedFolders(oldFolders.([]interface{})),
+			expandSharedFolders(newFolders.([]interface{}))); err != nil {
+			return diag.Errorf("Updating shared folders failed: %s", err.Error())
+		}
+	}
+
+	// Updating guest properties
+	if d.HasChange("guest_properties") {
+		oldProps, newProps := d.GetChange("guest_properties")
+		if err := updateGuestProperties(vb, vm, oldProps.(map[string]interface{}), newProps.(map[string]interface{})); err != nil {
+			return diag.Errorf("Updating guest properties failed: %s", err.Error())
+		}
 	}
 
 	if needChangeRules {
@@ -767,6 +1548,12 @@ func resourceVirtualBoxUpdate(ctx context.Context, d *schema.ResourceData, m int
 	// Virtual machine status management (startup/shutdown)
 	logrus.Printf("%s -> %s", vm.Spec.State, status)
 	if status != string(vm.Spec.State) {
+		// guest boots again only after poweroff, saved or paused guest keeps its properties
+		if vm.Spec.State == vbg.Poweroff || vm.Spec.State == vbg.Aborted {
+			if err := resetGuestProperty(d, vb, vm); err != nil {
+				return diag.Errorf("Resetting guest property failed: %s", err.Error())
+			}
+		}
 		if _, err := vb.ControlVM(vm, status); err != nil {
 			return diag.Errorf("Unable to running VM: %s", err.Error())
 		}
@@ -777,6 +1564,20 @@ func resourceVirtualBoxUpdate(ctx context.Context, d *schema.ResourceData, m int
 		}
 	}
 
+	// Sharing transient folders
+	if status == "running" {
+		if err := addTransientFolders(vb, vm, expandSharedFolders(d.Get("shared_folder").([]interface{}))); err != nil {
+			return diag.Errorf("Sharing folders failed: %s", err.Error())
+		}
+	}
+
+	// Waiting for guest, so that provisioners do not race the boot
+	if status == "running" {
+		if err := waitForGuest(ctx, d, vb, vm); err != nil {
+			return diag.Errorf("Waiting for guest failed: %s", err.Error())
+		}
+	}
+
 	// Updating Virtual Machine snapshots
 	snapshots := d.Get("snapshot.#").(int)
 
@@ -823,7 +1624,7 @@ func resourceVirtualBoxUpdate(ctx context.Context, d *schema.ResourceData, m int
 		}
 	}
 
-	return resourceVirtualBoxRead(ctx, d, m)
+	return append(diags, resourceVirtualBoxRead(ctx, d, m)...)
 }
 
 // snapshotOperationsHandler processes virtual machine snapshot operations
@@ -864,7 +1665,7 @@ func snapshotOperationsHandler(vb *vbg.VBox, vm *vbg.VirtualMachine, prevSnapsho
 // and interface m, which represents execution context
 // returns a boolean value
 func resourceVirtualBoxExists(d *schema.ResourceData, m interface{}) (bool, error) {
-	vb := vbg.NewVBox(vbg.Config{})
+	vb := m.(*Client).VBox(d.Get("basedir").(string))
 	_, err := vb.VMInfo(d.Id())
 	switch err {
 	case nil:
@@ -881,13 +1682,14 @@ func resourceVirtualBoxExists(d *schema.ResourceData, m interface{}) (bool, erro
 // returns diagnostic messages in case of errors.
 func resourceVirtualBoxDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	// Getting VM by id
-	homedir, err := os.UserHomeDir()
+	client := m.(*Client)
+	vb := client.VBox(d.Get("basedir").(string))
+	vm, err := vb.VMInfo(d.Id())
 	if err != nil {
-		return diag.Errorf("userhomedir failed: %s", err.Error())
+		return diag.Errorf("VMInfo failed: %s", err.Error())
 	}
 
-	vb := vbg.NewVBox(vbg.Config{BasePath: filepath.Join(homedir, d.Get("basedir").(string))})
-	vm, err := vb.VMInfo(d.Id())
+	info, err := pkg.VMInfoMap(vb, vm.UUID)
 	if err != nil {
 		return diag.Errorf("VMInfo failed: %s", err.Error())
 	}
@@ -897,25 +1699,160 @@ func resourceVirtualBoxDelete(ctx context.Context, d *schema.ResourceData, m int
 		return diag.Errorf("Setting state failed: %s", err.Error())
 	}
 
+	// Differencing disks of linked clone stay registered after unregistering VM
+	var media []string
+	if d.Get("clone_from.0.linked").(bool) {
+		media = pkg.MachineMedia(info)
+	}
+
 	// Unresitering VM
 	if err = vb.UnRegisterVM(vm); err != nil {
 		return diag.Errorf("VM Unregister failed: %s", err.Error())
 	}
 
+	for _, uuid := range media {
+		if _, err := pkg.Manage(vb, "closemedium", "disk", uuid); err != nil {
+			logrus.Warnf("Unable to close medium %s: %s", uuid, err.Error())
+		}
+	}
+
+	// Seed image stays registered as well
+	if !cloudInitSeed(d).Empty() {
+		if _, err := pkg.Manage(vb, "closemedium", "dvd", pkg.SeedPath(info)); err != nil {
+			logrus.Warnf("Unable to close cloud-init seed: %s", err.Error())
+		}
+	}
+
+	// Copied ISO image stays registered as well
+	installedData := filepath.Join(client.MachinePath(d.Get("basedir").(string)), "InstalledData", d.Get("name").(string))
+	for _, path := range info {
+		if filepath.Ext(path) == ".iso" && strings.HasPrefix(path, installedData+string(filepath.Separator)) {
+			if _, err := pkg.Manage(vb, "closemedium", "dvd", path); err != nil {
+				logrus.Warnf("Unable to close image %s: %s", path, err.Error())
+			}
+		}
+	}
+
 	// VM deletion
 	if err = vb.DeleteVM(vm); err != nil {
 		return diag.Errorf("VM deletion failed: %s", err.Error())
 	}
 
-	// Delete machine folder
-	machineDir := filepath.Join(homedir, d.Get("basedir").(string))
-	if err := os.RemoveAll(machineDir); err != nil {
+	// Delete machine folder, basedir is only removed if provider created it
+	machineDir := ""
+	if d.Get("basedir_created").(bool) {
+		machineDir = client.MachinePath(d.Get("basedir").(string))
+	}
+	if err := removeMachineDir(machineDir, filepath.Dir(info["CfgFile"])); err != nil {
+		return diag.Errorf("Can't clear the data: %s", err.Error())
+	}
+
+	// Images unpacked or copied for this VM
+	if err := os.RemoveAll(installedData); err != nil {
 		return diag.Errorf("Can't clear the data: %s", err.Error())
 	}
 
 	return nil
 }
 
+// removeMachineDir deletes folder of virtual machine vmDir and basedir machineDir
+// empty machineDir keeps basedir, it is also kept if other virtual machines are located in it
+func removeMachineDir(machineDir, vmDir string) error {
+	if err := os.RemoveAll(vmDir); err != nil {
+		return err
+	}
+
+	if machineDir == "" {
+		return nil
+	}
+
+	shared := false
+	filepath.WalkDir(machineDir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && filepath.Ext(path) == ".vbox" {
+			shared = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+
+	if shared {
+		logrus.Infof("Keeping %s, other virtual machines are located in it", machineDir)
+		return nil
+	}
+
+	return os.RemoveAll(machineDir)
+}
+
+// resourceVirtualBoxImport adopts existing virtual machine by UUID or name
+// basedir is derived from location of VM settings file, other attributes are filled by Read
+func resourceVirtualBoxImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*Client)
+	vb := client.VBox("")
+
+	vm, err := vb.VMInfo(d.Id())
+	if err != nil {
+		return nil, fmt.Errorf("VM %s not found: %s", d.Id(), err.Error())
+	}
+
+	info, err := pkg.VMInfoMap(vb, vm.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("VMInfo failed: %s", err.Error())
+	}
+
+	// Settings file is located in <basedir>/<group>/<name>/<name>.vbox
+	basePath := filepath.Dir(filepath.Dir(info["CfgFile"]))
+	group := strings.Split(info["groups"], ",")[0]
+	if group != "/" {
+		basePath = strings.TrimSuffix(basePath, filepath.FromSlash(group))
+	}
+
+	basedir := basePath
+	if rel, err := filepath.Rel(client.MachineFolder, basePath); err == nil && !strings.HasPrefix(rel, "..") {
+		basedir = rel
+	}
+
+	if err := d.Set("basedir", basedir); err != nil {
+		return nil, fmt.Errorf("didn't manage to set basedir: %s", err.Error())
+	}
+
+	// Basedir of imported VM was not created by provider and is kept on destroy
+	if err := d.Set("basedir_created", false); err != nil {
+		return nil, fmt.Errorf("didn't manage to set basedir_created: %s", err.Error())
+	}
+
+	// Attributes which can not be read back from VM get their defaults
+	for _, key := range []string{"user_data", "meta_data", "network_config"} {
+		if err := d.Set(key, ""); err != nil {
+			return nil, fmt.Errorf("didn't manage to set %s: %s", key, err.Error())
+		}
+	}
+
+	d.SetId(vm.UUID)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// osTypeID returns ID of guest OS type by its description, as VBoxManage shows only description
+// returns empty string if OS type is unknown
+func osTypeID(vb *vbg.VBox, description string) (string, error) {
+	if description == "" {
+		return "", nil
+	}
+
+	osTypes, err := vb.ListOSTypes()
+	if err != nil {
+		return "", err
+	}
+
+	for id, osType := range osTypes {
+		if id == description || osType.Description == description {
+			return id, nil
+		}
+	}
+
+	return "", nil
+}
+
 // setState sets state of virtual machine in schema object.ResourceData
 // function accepts a pointer to schema object.ResourceData d, which represents state of resource,
 // and a pointer to vbs.VirtualMachine vm object, which contains information about state of virtual machine
@@ -937,7 +1874,16 @@ func setState(d *schema.ResourceData, vm *vbg.VirtualMachine) error {
 	return err
 }
 
+// setSnapshots sets list of snapshots of virtual machine in schema object.ResourceData
 func setSnapshots(d *schema.ResourceData, vm *vbg.VirtualMachine) error {
+	if err := d.Set("snapshot", flattenSnapshots(vm)); err != nil {
+		return fmt.Errorf("%s", err.Error())
+	}
+	return nil
+}
+
+// flattenSnapshots returns snapshots of virtual machine in form of "snapshot" list
+func flattenSnapshots(vm *vbg.VirtualMachine) []map[string]interface{} {
 	arr := make([]map[string]interface{}, 0, 3)
 
 	for i := 0; i < len(vm.Spec.Snapshots); i++ {
@@ -958,85 +1904,681 @@ func setSnapshots(d *schema.ResourceData, vm *vbg.VirtualMachine) error {
 
 	}
 
-	if err := d.Set("snapshot", arr); err != nil {
-		return fmt.Errorf("%s", err.Error())
-	}
-	return nil
+	return arr
 }
 
-// setNetwork sets information about network adapters in schema object.ResourceData
-// based on data about network interfaces of virtual machine
-// function accepts a pointer to schema object.ResourceData d, which represents state of resource,
-// and a pointer to vbs.VirtualMachine vm object, which contains information about network interfaces of VM
-// function creates an array with information about each network adapter of virtual machine and
-// installs it in object d under key "network_adapter", each element of array contains adapter index,
-// network mode, adapter type, and cable connection status
-func setNetwork(d *schema.ResourceData, vm *vbg.VirtualMachine) error {
+// vmDisk is slot of disk loaded with image, url or disk
+var vmDisk = pkg.StorageDisk{Controller: "sata", Port: 0, Device: 0}
 
-	// getType helper function returns a string representation of type of network adapter
-	getType := func(nic vbg.NIC) string {
-		switch nic.Type {
-		case vbg.NIC_Am79C970A:
-			return "Am79C970A"
-		case vbg.NIC_Am79C973:
-			return "Am79C973"
-		case vbg.NIC_82540EM:
-			return "82540EM"
-		case vbg.NIC_82543GC:
-			return "82543GC"
-		case vbg.NIC_82545EM:
-			return "82545EM"
-		case vbg.NIC_virtio:
-			return "virtio"
-		default:
-			return ""
-		}
+// setDiskSize sets disk_size in schema object.ResourceData to logical size of VM disk
+// nothing is set if VM has no disk
+func setDiskSize(d *schema.ResourceData, vb *vbg.VBox, info map[string]string) error {
+	_, uuid := pkg.AttachedDisk(info, vmDisk)
+	if uuid == "" {
+		return nil
 	}
 
-	// getMode helper function returns a string representation of network mode for network adapter
-	getMode := func(nic vbg.NIC) string {
-		switch nic.Mode {
-		case vbg.NWMode_none:
-			return "none"
-		case vbg.NWMode_null:
-			return "null"
-		case vbg.NWMode_nat:
-			return "nat"
-		case vbg.NWMode_natnetwork:
-			return "natnetwork"
-		case vbg.NWMode_bridged:
-			return "bridged"
-		case vbg.NWMode_intnet:
-			return "intnet"
-		case vbg.NWMode_hostonly:
-			return "hostonly"
-		case vbg.NWMode_generic:
-			return "generic"
-		default:
-			return ""
-		}
+	medium, err := pkg.MediumInfo(vb, uuid)
+	if err != nil {
+		return err
 	}
 
-	//velociped
-	if len(vm.Spec.NICs) == 1 {
-		if vm.Spec.NICs[0].Mode == "nat" && vm.Spec.NICs[0].Type == "82540EM" {
-			return nil
-		}
+	size, err := pkg.MediumSizeMB(medium)
+	if err != nil {
+		return err
 	}
 
-	// Creating empty array to store information about network adapters
-	nics := make([]map[string]any, 0, 4)
-	// Iterating through all network adapters of virtual machine and create information about each adapter
-	for i, nic := range vm.Spec.NICs {
-		out := make(map[string]any)
-		out["index"] = i + 1
-		out["network_mode"] = getMode(nic)
-		out["nic_type"] = getType(nic)
-		out["cable_connected"] = nic.CableConnected
-		out["name"] = nic.NetworkName
+	return d.Set("disk_size", int(size))
+}
 
-		rules := make([]map[string]any, 0, 3)
-		for j := 0; j < len(nic.PortForwarding); j++ {
+// errNoVMDisk is returned by resizeVMDisk when nothing is attached to slot of VM disk,
+// e.g appliance or clone keeping its disk on other controller
+var errNoVMDisk = errors.New("no disk is attached to SATA Controller port 0")
+
+// noVMDiskWarning tells that disk_size was not applied to VM without disk in slot of VM disk
+func noVMDiskWarning(vm *vbg.VirtualMachine) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "disk_size was not applied",
+		Detail:   fmt.Sprintf("VM %s: %s, disk_size only resizes disk attached there.", vm.Spec.Name, errNoVMDisk.Error()),
+	}
+}
+
+// resizeVMDisk grows disk loaded with image, url or disk up to newSize
+// errNoVMDisk is returned if VM has no disk in slot of VM disk
+func resizeVMDisk(vb *vbg.VBox, vm *vbg.VirtualMachine, newSize int64) error {
+	info, err := pkg.VMInfoMap(vb, vm.UUIDOrName())
+	if err != nil {
+		return err
+	}
+
+	_, uuid := pkg.AttachedDisk(info, vmDisk)
+	if uuid == "" {
+		return errNoVMDisk
+	}
+
+	medium, err := pkg.MediumInfo(vb, uuid)
+	if err != nil {
+		return err
+	}
+
+	size, err := pkg.MediumSizeMB(medium)
+	if err != nil {
+		return err
+	}
+
+	if newSize < size {
+		return fmt.Errorf("disk can not be shrunk from %d MB to %d MB", size, newSize)
+	}
+	if newSize == size {
+		return nil
+	}
+
+	return pkg.ResizeDisk(vb, uuid, newSize)
+}
+
+// expandCPUFeatures returns CPU features from schema object.ResourceData
+// features missing in configuration keep current values
+func expandCPUFeatures(d *schema.ResourceData, current pkg.CPUFeatures) pkg.CPUFeatures {
+	f := current
+	f.ExecutionCap = d.Get("cpu_execution_cap").(int)
+	f.NestedVirtualization = d.Get("nested_virtualization").(bool)
+
+	if isConfigured(d, "pae") {
+		f.PAE = d.Get("pae").(bool)
+	}
+	if isConfigured(d, "long_mode") {
+		f.LongMode = d.Get("long_mode").(bool)
+	}
+	if isConfigured(d, "hpet") {
+		f.HPET = d.Get("hpet").(bool)
+	}
+	if isConfigured(d, "paravirt_provider") {
+		f.ParavirtProvider = d.Get("paravirt_provider").(string)
+	}
+	if isConfigured(d, "nested_paging") {
+		f.NestedPaging = d.Get("nested_paging").(bool)
+	}
+	if isConfigured(d, "large_pages") {
+		f.LargePages = d.Get("large_pages").(bool)
+	}
+	return f
+}
+
+// isConfigured checks whether attribute is set in configuration, which is needed for Optional and Computed attributes
+func isConfigured(d *schema.ResourceData, key string) bool {
+	config := d.GetRawConfig()
+	return !config.IsNull() && !config.GetAttr(key).IsNull()
+}
+
+// setCPUFeatures sets CPU features in schema object.ResourceData
+func setCPUFeatures(d *schema.ResourceData, f pkg.CPUFeatures) error {
+	values := map[string]interface{}{
+		"cpu_execution_cap":     f.ExecutionCap,
+		"nested_virtualization": f.NestedVirtualization,
+		"pae":                   f.PAE,
+		"long_mode":             f.LongMode,
+		"hpet":                  f.HPET,
+		"paravirt_provider":     f.ParavirtProvider,
+		"nested_paging":         f.NestedPaging,
+		"large_pages":           f.LargePages,
+	}
+	for key, value := range values {
+		if err := d.Set(key, value); err != nil {
+			return fmt.Errorf("%s: %s", key, err.Error())
+		}
+	}
+	return nil
+}
+
+// expandDisplay returns display settings from schema object.ResourceData
+// video memory and graphics controller missing in configuration keep current values
+func expandDisplay(d *schema.ResourceData, current pkg.Display) pkg.Display {
+	display := current
+	if isConfigured(d, "video_memory") {
+		display.VideoMemory = d.Get("video_memory").(int)
+	}
+	if isConfigured(d, "graphics_controller") {
+		display.GraphicsController = d.Get("graphics_controller").(string)
+	}
+	display.Accelerate3D = d.Get("accelerate_3d").(bool)
+	display.MonitorCount = d.Get("monitor_count").(int)
+
+	display.VRDE.Enabled = false
+	if _, ok := d.GetOk("vrde"); ok {
+		display.VRDE = pkg.VRDE{
+			Enabled:  d.Get("vrde.0.enabled").(bool),
+			Port:     d.Get("vrde.0.port").(string),
+			Address:  d.Get("vrde.0.address").(string),
+			AuthType: d.Get("vrde.0.auth_type").(string),
+		}
+	}
+	return display
+}
+
+// setDisplay sets display settings and port of remote desktop in schema object.ResourceData
+// vrde block is kept empty while server is disabled and not configured
+func setDisplay(d *schema.ResourceData, info map[string]string) error {
+	display, port := pkg.ReadDisplay(info)
+
+	vrde := []map[string]interface{}{}
+	if _, ok := d.GetOk("vrde"); ok || display.VRDE.Enabled {
+		server := map[string]interface{}{
+			"enabled":   display.VRDE.Enabled,
+			"port":      display.VRDE.Port,
+			"address":   display.VRDE.Address,
+			"auth_type": display.VRDE.AuthType,
+		}
+		// Settings of disabled server are not applied, so configured ones are kept
+		if !display.VRDE.Enabled {
+			for _, key := range []string{"port", "address", "auth_type"} {
+				server[key] = d.Get("vrde.0." + key)
+			}
+		}
+		vrde = append(vrde, server)
+	}
+
+	values := map[string]interface{}{
+		"video_memory":        display.VideoMemory,
+		"graphics_controller": display.GraphicsController,
+		"accelerate_3d":       display.Accelerate3D,
+		"monitor_count":       display.MonitorCount,
+		"vrde":                vrde,
+		"vrde_port":           port,
+	}
+	for key, value := range values {
+		if err := d.Set(key, value); err != nil {
+			return fmt.Errorf("%s: %s", key, err.Error())
+		}
+	}
+	return nil
+}
+
+// expandPlatform returns firmware, boot order and chipset settings from schema object.ResourceData
+func expandPlatform(d *schema.ResourceData) pkg.Platform {
+	return pkg.Platform{
+		Firmware:   d.Get("firmware").(string),
+		BootOrder:  expandStringList(d.Get("boot_order").([]interface{})),
+		Chipset:    d.Get("chipset").(string),
+		SecureBoot: d.Get("secure_boot").(bool),
+		TPMType:    d.Get("tpm_type").(string),
+		RTCUseUTC:  d.Get("rtc_use_utc").(bool),
+		IOAPIC:     d.Get("ioapic").(bool),
+	}
+}
+
+// setPlatform sets firmware, boot order and chipset settings in schema object.ResourceData
+func setPlatform(d *schema.ResourceData, p pkg.Platform) error {
+	values := map[string]interface{}{
+		"firmware":    p.Firmware,
+		"boot_order":  p.BootOrder,
+		"chipset":     p.Chipset,
+		"secure_boot": p.SecureBoot,
+		"tpm_type":    p.TPMType,
+		"rtc_use_utc": p.RTCUseUTC,
+		"ioapic":      p.IOAPIC,
+	}
+	for key, value := range values {
+		if err := d.Set(key, value); err != nil {
+			return fmt.Errorf("%s: %s", key, err.Error())
+		}
+	}
+	return nil
+}
+
+// waitForGuest blocks until guest sets property from wait_for_guest, nothing is done if block is not set
+func waitForGuest(ctx context.Context, d *schema.ResourceData, vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	if _, ok := d.GetOk("wait_for_guest"); !ok {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(d.Get("wait_for_guest.0.timeout").(string))
+	if err != nil {
+		return err
+	}
+	property := d.Get("wait_for_guest.0.property").(string)
+
+	value, err := pkg.WaitForGuestProperty(ctx, vb, vm, property, timeout)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Guest of %s is ready, %s = %s", vm.Spec.Name, property, value)
+	return nil
+}
+
+// resetGuestProperty unsets property from wait_for_guest before VM is started,
+// otherwise value left from previous boot would be taken for ready guest
+func resetGuestProperty(d *schema.ResourceData, vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	if _, ok := d.GetOk("wait_for_guest"); !ok {
+		return nil
+	}
+	return pkg.DeleteGuestProperty(vb, vm, d.Get("wait_for_guest.0.property").(string))
+}
+
+// setGuestAddresses sets ipv4_addresses and ipv6_addresses in schema object.ResourceData
+// guest properties outlive guest, so addresses are only reported while VM is running
+func setGuestAddresses(d *schema.ResourceData, vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	ipv4, ipv6 := []string{}, []string{}
+	if vm.Spec.State == vbg.Running {
+		var err error
+		if ipv4, ipv6, err = pkg.GuestAddresses(vb, vm); err != nil {
+			return err
+		}
+	}
+
+	if err := d.Set("ipv4_addresses", ipv4); err != nil {
+		return err
+	}
+	return d.Set("ipv6_addresses", ipv6)
+}
+
+// expandSharedFolders converts shared_folder blocks to pkg.SharedFolder
+func expandSharedFolders(list []interface{}) []pkg.SharedFolder {
+	folders := make([]pkg.SharedFolder, 0, len(list))
+	for _, item := range list {
+		folder := item.(map[string]interface{})
+		folders = append(folders, pkg.SharedFolder{
+			Name:       folder["name"].(string),
+			HostPath:   folder["host_path"].(string),
+			ReadOnly:   folder["read_only"].(bool),
+			AutoMount:  folder["auto_mount"].(bool),
+			MountPoint: folder["mount_point"].(string),
+			Transient:  folder["transient"].(bool),
+		})
+	}
+	return folders
+}
+
+// updateSharedFolders removes permanent folders missing in newFolders or changed, then adds new ones
+// transient folders are skipped, they are shared by addTransientFolders
+func updateSharedFolders(vb *vbg.VBox, vm *vbg.VirtualMachine, oldFolders, newFolders []pkg.SharedFolder) error {
+	contains := func(folders []pkg.SharedFolder, folder pkg.SharedFolder) bool {
+		for _, f := range folders {
+			if f == folder {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, folder := range oldFolders {
+		if !folder.Transient && !contains(newFolders, folder) {
+			if err := pkg.RemoveSharedFolder(vb, vm, folder); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, folder := range newFolders {
+		if !folder.Transient && !contains(oldFolders, folder) {
+			if err := pkg.AddSharedFolder(vb, vm, folder); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addTransientFolders shares transient folders with running VM
+func addTransientFolders(vb *vbg.VBox, vm *vbg.VirtualMachine, folders []pkg.SharedFolder) error {
+	for _, folder := range folders {
+		if folder.Transient {
+			if err := pkg.AddSharedFolder(vb, vm, folder); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setSharedFolders sets shared_folder in schema object.ResourceData
+// folders are kept in order of configuration, folders shared outside of Terraform go last
+func setSharedFolders(d *schema.ResourceData, vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	actual, err := pkg.SharedFolders(vb, vm)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(actual))
+	for i, folder := range actual {
+		index[folder.Name] = i
+	}
+
+	var ordered []pkg.SharedFolder
+	for _, folder := range expandSharedFolders(d.Get("shared_folder").([]interface{})) {
+		if i, ok := index[folder.Name]; ok {
+			ordered = append(ordered, actual[i])
+			delete(index, folder.Name)
+		}
+	}
+	for _, folder := range actual {
+		if _, ok := index[folder.Name]; ok {
+			ordered = append(ordered, folder)
+		}
+	}
+
+	list := make([]map[string]interface{}, 0, len(ordered))
+	for _, folder := range ordered {
+		list = append(list, map[string]interface{}{
+			"name":        folder.Name,
+			"host_path":   folder.HostPath,
+			"read_only":   folder.ReadOnly,
+			"auto_mount":  folder.AutoMount,
+			"mount_point": folder.MountPoint,
+			"transient":   folder.Transient,
+		})
+	}
+	return d.Set("shared_folder", list)
+}
+
+// updateGuestProperties removes guest properties missing in newProps and sets changed ones
+func updateGuestProperties(vb *vbg.VBox, vm *vbg.VirtualMachine, oldProps, newProps map[string]interface{}) error {
+	for name := range oldProps {
+		if _, ok := newProps[name]; !ok {
+			if err := pkg.DeleteGuestProperty(vb, vm, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, value := range newProps {
+		if old, ok := oldProps[name]; ok && old == value {
+			continue
+		}
+		if err := pkg.SetGuestProperty(vb, vm, name, value.(string), ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setGuestProperties sets guest_properties in schema object.ResourceData to current values of managed properties
+// properties removed outside of Terraform are dropped, so that they are set again
+func setGuestProperties(d *schema.ResourceData, vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	managed := d.Get("guest_properties").(map[string]interface{})
+	if len(managed) == 0 {
+		return nil
+	}
+
+	props, err := pkg.GuestProperties(vb, vm, "")
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]string, len(managed))
+	for name := range managed {
+		if prop, ok := props[name]; ok {
+			current[name] = prop.Value
+		}
+	}
+	return d.Set("guest_properties", current)
+}
+
+// validateDuration checks that value can be parsed by time.ParseDuration
+func validateDuration(v interface{}, k string) ([]string, []error) {
+	if _, err := time.ParseDuration(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%s must be a duration like 5m: %s", k, err.Error())}
+	}
+	return nil, nil
+}
+
+// cloudInitSeed returns content of cloud-init seed image from schema object.ResourceData
+func cloudInitSeed(d *schema.ResourceData) pkg.CloudInitSeed {
+	return pkg.CloudInitSeed{
+		UserData:      d.Get("user_data").(string),
+		MetaData:      d.Get("meta_data").(string),
+		NetworkConfig: d.Get("network_config").(string),
+	}
+}
+
+// expandStorage converts "storage" list to data disks
+// disk files are placed in machinesDir and named after VM and slot of disk
+func expandStorage(list []interface{}, vmName string, machinesDir string) []pkg.StorageDisk {
+	disks := make([]pkg.StorageDisk, 0, len(list))
+	for _, item := range list {
+		val := item.(map[string]interface{})
+		disk := pkg.StorageDisk{
+			SizeMB:        int64(val["size"].(int)),
+			DiskID:        val["disk_id"].(string),
+			Format:        val["format"].(string),
+			Controller:    val["controller"].(string),
+			Port:          val["port"].(int),
+			Device:        val["device"].(int),
+			NonRotational: val["non_rotational"].(bool),
+			HotPluggable:  val["hot_pluggable"].(bool),
+			Discard:       val["discard"].(bool),
+		}
+		disk.Path = filepath.Join(machinesDir, fmt.Sprintf("%s-%s.%s", vmName, disk.Slot(), disk.Format))
+		disks = append(disks, disk)
+	}
+	return disks
+}
+
+// expandStoredStorage returns data disks from state, existing disks keep path and UUID stored in state,
+// as name of VM, which paths are built from, could be changed since they were created
+func expandStoredStorage(list []interface{}, vmName string, machinesDir string) []pkg.StorageDisk {
+	disks := expandStorage(list, vmName, machinesDir)
+	for i, item := range list {
+		val := item.(map[string]interface{})
+		if path, _ := val["path"].(string); path != "" {
+			disks[i].Path = path
+		}
+		if uuid, _ := val["uuid"].(string); uuid != "" {
+			disks[i].UUID = uuid
+		}
+	}
+	return disks
+}
+
+// updateStorage attaches new data disks, detaches and deletes removed ones
+// and applies changes of size and flags to disks that stay in the same slot
+func updateStorage(vb *vbg.VBox, vm *vbg.VirtualMachine, oldDisks []pkg.StorageDisk, newDisks []pkg.StorageDisk) error {
+	oldSlots := make(map[string]pkg.StorageDisk)
+	for _, disk := range oldDisks {
+		oldSlots[disk.Slot()] = disk
+	}
+
+	newSlots := make(map[string]pkg.StorageDisk)
+	for _, disk := range newDisks {
+		newSlots[disk.Slot()] = disk
+	}
+
+	// Removing disks whose blocks were deleted or whose format or disk_id was changed,
+	// disks with disk_id are only detached
+	for slot, disk := range oldSlots {
+		if newDisk, ok := newSlots[slot]; !ok || newDisk.Format != disk.Format || newDisk.DiskID != disk.DiskID {
+			if err := pkg.DetachDisk(vb, vm, disk, true); err != nil {
+				return err
+			}
+			delete(oldSlots, slot)
+		}
+	}
+
+	for _, disk := range newDisks {
+		oldDisk, ok := oldSlots[disk.Slot()]
+		if !ok {
+			if err := pkg.AttachDisk(vb, vm, &disk); err != nil {
+				return err
+			}
+			continue
+		}
+
+		disk.Path = oldDisk.Path
+		disk.UUID = oldDisk.UUID
+
+		// Size of disk with disk_id is managed by virtualbox_disk
+		if disk.DiskID != "" {
+			disk.SizeMB = oldDisk.SizeMB
+		}
+
+		if disk.SizeMB < oldDisk.SizeMB {
+			return fmt.Errorf("disk %s can not be shrunk from %d MB to %d MB", disk.Slot(), oldDisk.SizeMB, disk.SizeMB)
+		}
+
+		if disk.SizeMB > oldDisk.SizeMB {
+			if err := pkg.ResizeDisk(vb, disk.Medium(), disk.SizeMB); err != nil {
+				return err
+			}
+		}
+
+		if disk.NonRotational != oldDisk.NonRotational ||
+			disk.HotPluggable != oldDisk.HotPluggable ||
+			disk.Discard != oldDisk.Discard {
+			if err := pkg.DetachDisk(vb, vm, oldDisk, false); err != nil {
+				return err
+			}
+			if err := pkg.AttachDisk(vb, vm, &disk); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// setStorage sets data disks in schema object.ResourceData
+// disks that are no longer attached to VM are dropped, path, uuid and size are taken from attached medium
+func setStorage(d *schema.ResourceData, vb *vbg.VBox, info map[string]string) error {
+	list := d.Get("storage").([]interface{})
+	disks := make([]map[string]interface{}, 0, len(list))
+
+	for i, disk := range expandStorage(list, "", "") {
+		path, uuid := pkg.AttachedDisk(info, disk)
+		if path == "" {
+			continue
+		}
+
+		medium, err := pkg.MediumInfo(vb, uuid)
+		if err != nil {
+			return err
+		}
+
+		size, err := pkg.MediumSizeMB(medium)
+		if err != nil {
+			return err
+		}
+
+		val := list[i].(map[string]interface{})
+		val["path"] = path
+		val["uuid"] = uuid
+		val["size"] = int(size)
+		disks = append(disks, val)
+	}
+
+	return d.Set("storage", disks)
+}
+
+// setNetwork sets information about network adapters in schema object.ResourceData
+// based on data about network interfaces of virtual machine
+// function accepts a pointer to schema object.ResourceData d, which represents state of resource,
+// and a pointer to vbs.VirtualMachine vm object, which contains information about network interfaces of VM
+// function creates an array with information about each network adapter of virtual machine and
+// installs it in object d under key "network_adapter", each element of array contains adapter index,
+// network mode, adapter type, cable connection status, MAC address and settings read from "showvminfo"
+func setNetwork(d *schema.ResourceData, vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	//velociped
+	if len(vm.Spec.NICs) == 1 {
+		if vm.Spec.NICs[0].Mode == "nat" && vm.Spec.NICs[0].Type == "82540EM" {
+			return nil
+		}
+	}
+
+	settings, err := pkg.NICSettingsOf(vb, vm)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Set("network_adapter", flattenNetwork(vm, settings)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// flattenNetwork returns network adapters of virtual machine in form of "network_adapter" list
+// settings are keyed by index of adapter
+func flattenNetwork(vm *vbg.VirtualMachine, settings map[int]pkg.NICSettings) []map[string]any {
+
+	// getType helper function returns a string representation of type of network adapter
+	getType := func(nic vbg.NIC) string {
+		switch nic.Type {
+		case vbg.NIC_Am79C970A:
+			return "Am79C970A"
+		case vbg.NIC_Am79C973:
+			return "Am79C973"
+		case vbg.NIC_82540EM:
+			return "82540EM"
+		case vbg.NIC_82543GC:
+			return "82543GC"
+		case vbg.NIC_82545EM:
+			return "82545EM"
+		case vbg.NIC_virtio:
+			return "virtio"
+		default:
+			return ""
+		}
+	}
+
+	// getMode helper function returns a string representation of network mode for network adapter
+	getMode := func(nic vbg.NIC) string {
+		switch nic.Mode {
+		case vbg.NWMode_none:
+			return "none"
+		case vbg.NWMode_null:
+			return "null"
+		case vbg.NWMode_nat:
+			return "nat"
+		case vbg.NWMode_natnetwork:
+			return "natnetwork"
+		case vbg.NWMode_bridged:
+			return "bridged"
+		case vbg.NWMode_intnet:
+			return "intnet"
+		case vbg.NWMode_hostonly:
+			return "hostonly"
+		case "hostonlynet":
+			return "hostonlynet"
+		case vbg.NWMode_generic:
+			return "generic"
+		default:
+			return ""
+		}
+	}
+
+	// Creating empty array to store information about network adapters
+	nics := make([]map[string]any, 0, 4)
+	// Iterating through all network adapters of virtual machine and create information about each adapter
+	for i, nic := range vm.Spec.NICs {
+		out := make(map[string]any)
+		out["index"] = i + 1
+		out["network_mode"] = getMode(nic)
+		out["nic_type"] = getType(nic)
+		out["cable_connected"] = nic.CableConnected
+		out["name"] = nic.NetworkName
+		out["mac_address"] = pkg.FormatMAC(nic.MAC)
+
+		nicSettings, ok := settings[i+1]
+		if !ok {
+			nicSettings = pkg.NICSettings{PromiscuousMode: "deny"}
+		}
+		out["promiscuous_mode"] = nicSettings.PromiscuousMode
+		out["boot_priority"] = nicSettings.BootPriority
+		out["bandwidth_group"] = nicSettings.BandwidthGroup
+		out["trace_file"] = nicSettings.TraceFile
+		out["nat_network"] = nicSettings.NAT.Network
+		out["dns_proxy"] = nicSettings.NAT.DNSProxy
+		out["dns_host_resolver"] = nicSettings.NAT.DNSHostResolver
+		out["tftp_prefix"] = nicSettings.NAT.TFTPPrefix
+		out["tftp_file"] = nicSettings.NAT.TFTPFile
+		out["bind_ip"] = nicSettings.NAT.BindIP
+		aliasMode := make([]interface{}, 0, len(nicSettings.NAT.AliasMode))
+		for _, flag := range nicSettings.NAT.AliasMode {
+			aliasMode = append(aliasMode, flag)
+		}
+		out["alias_mode"] = aliasMode
+
+		rules := make([]map[string]any, 0, 3)
+		for j := 0; j < len(nic.PortForwarding); j++ {
 			protocol := "tcp"
 			if nic.PortForwarding[j].Protocol == vbg.UDP {
 				protocol = "udp"
@@ -1056,10 +2598,109 @@ func setNetwork(d *schema.ResourceData, vm *vbg.VirtualMachine) error {
 		nics = append(nics, out)
 	}
 
-	if err := d.Set("network_adapter", nics); err != nil {
+	return nics
+}
+
+// updateNICSettings applies MAC addresses and other settings of network adapters which virtualbox-go does not manage
+func updateNICSettings(d *schema.ResourceData, vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	current, err := pkg.NICSettingsOf(vb, vm)
+	if err != nil {
 		return err
 	}
 
+	nicNumber := d.Get("network_adapter.#").(int)
+	for i := 0; i < nicNumber; i++ {
+		if err := pkg.SetNICSettings(vb, vm, i+1, expandNICSettings(d, i), current[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandNICSettings returns settings of network adapter from schema object.ResourceData
+func expandNICSettings(d *schema.ResourceData, i int) pkg.NICSettings {
+	return pkg.NICSettings{
+		MAC:             d.Get(fmt.Sprintf("network_adapter.%d.mac_address", i)).(string),
+		PromiscuousMode: d.Get(fmt.Sprintf("network_adapter.%d.promiscuous_mode", i)).(string),
+		BootPriority:    d.Get(fmt.Sprintf("network_adapter.%d.boot_priority", i)).(int),
+		BandwidthGroup:  d.Get(fmt.Sprintf("network_adapter.%d.bandwidth_group", i)).(string),
+		TraceFile:       d.Get(fmt.Sprintf("network_adapter.%d.trace_file", i)).(string),
+		NAT:             expandNATSettings(d, i),
+	}
+}
+
+// expandNATSettings returns settings of NAT engine of network adapter from schema object.ResourceData
+func expandNATSettings(d *schema.ResourceData, i int) pkg.NATSettings {
+	return pkg.NATSettings{
+		Network:         d.Get(fmt.Sprintf("network_adapter.%d.nat_network", i)).(string),
+		DNSProxy:        d.Get(fmt.Sprintf("network_adapter.%d.dns_proxy", i)).(bool),
+		DNSHostResolver: d.Get(fmt.Sprintf("network_adapter.%d.dns_host_resolver", i)).(bool),
+		AliasMode:       expandStringList(d.Get(fmt.Sprintf("network_adapter.%d.alias_mode", i)).(*schema.Set).List()),
+		TFTPPrefix:      d.Get(fmt.Sprintf("network_adapter.%d.tftp_prefix", i)).(string),
+		TFTPFile:        d.Get(fmt.Sprintf("network_adapter.%d.tftp_file", i)).(string),
+		BindIP:          d.Get(fmt.Sprintf("network_adapter.%d.bind_ip", i)).(string),
+	}
+}
+
+var reMAC = regexp.MustCompile(`^[0-9A-Fa-f]{2}([:-]?[0-9A-Fa-f]{2}){5}$`)
+
+// validateMAC checks that value is unicast MAC address, VirtualBox rejects multicast ones
+func validateMAC(v interface{}, k string) ([]string, []error) {
+	mac := v.(string)
+	if !reMAC.MatchString(mac) {
+		return nil, []error{fmt.Errorf("%s must be MAC address like 08:00:27:a1:b2:c3, got %q", k, mac)}
+	}
+	if first, _ := strconv.ParseUint(mac[:2], 16, 8); first&1 == 1 {
+		return nil, []error{fmt.Errorf("%s must be unicast MAC address, the lowest bit of first byte must be 0, got %q", k, mac)}
+	}
+	return nil, nil
+}
+
+// suppressEqualMAC ignores difference in case and separators of MAC addresses
+func suppressEqualMAC(k, old, new string, d *schema.ResourceData) bool {
+	return pkg.NormalizeMAC(old) == pkg.NormalizeMAC(new)
+}
+
+// validateBridgedAdapters checks at plan time that bridged adapters are attached to existing host interfaces,
+// as VM with unknown interface starts without network and without any error
+func validateBridgedAdapters(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.HasChange("network_adapter") {
+		return nil
+	}
+
+	var names []string
+	for i, adapter := range d.Get("network_adapter").([]interface{}) {
+		nic, ok := adapter.(map[string]interface{})
+		if !ok || nic["network_mode"].(string) != "bridged" {
+			continue
+		}
+		// Name is unknown until other resources are created
+		if !d.NewValueKnown(fmt.Sprintf("network_adapter.%d.name", i)) || nic["name"].(string) == "" {
+			continue
+		}
+		names = append(names, nic["name"].(string))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	hostInterfaces, err := pkg.HostInterfaces(m.(*Client).VBox(""))
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool, len(hostInterfaces))
+	available := make([]string, 0, len(hostInterfaces))
+	for _, hostInterface := range hostInterfaces {
+		existing[hostInterface.Name] = true
+		available = append(available, hostInterface.Name)
+	}
+
+	for _, name := range names {
+		if !existing[name] {
+			return fmt.Errorf("host interface %q of bridged network adapter does not exist, available interfaces: %s", name, strings.Join(available, ", "))
+		}
+	}
 	return nil
 }
 
@@ -1073,14 +2714,15 @@ func validateVmParams(d *schema.ResourceData, isCreate bool) error {
 	amountOfProblems := 0
 	var error_output []string
 
+	// unset cpus and memory are taken from defaults, imported appliance or clone source
 	cpus := d.Get("cpus").(int)
-	if cpus <= 0 || cpus >= runtime.NumCPU() {
+	if isConfigured(d, "cpus") && (cpus <= 0 || cpus >= runtime.NumCPU()) {
 		error_output = append(error_output, fmt.Sprintf("Set the number of CPUs according to the following limits: 1 - %v", runtime.NumCPU()))
 		amountOfProblems++
 	}
 
 	memory := d.Get("memory").(int)
-	if memory <= 0 || memory > int(mem.TotalMemory()) {
+	if isConfigured(d, "memory") && (memory <= 0 || memory > int(mem.TotalMemory())) {
 		error_output = append(error_output, fmt.Sprintf("Set the amount of memory according to the following limits: 1 - %v", mem.TotalMemory()))
 		amountOfProblems++
 	}
@@ -1142,6 +2784,85 @@ func validateVmParams(d *schema.ResourceData, isCreate bool) error {
 		amountOfProblems++
 	}
 
+	if d.Get("clone_from.0.linked").(bool) && d.Get("clone_from.0.snapshot").(string) == "" {
+		error_output = append(error_output, "Linked clone can only be created from a snapshot, set clone_from.snapshot")
+		amountOfProblems++
+	}
+
+	// Checking data disks
+	reservedSlots := map[string]bool{}
+	if _, ok := d.GetOk("image"); ok {
+		reservedSlots["sata-0-0"], reservedSlots["ide-1-0"] = true, true
+	}
+	if _, ok := d.GetOk("url"); ok {
+		reservedSlots["sata-0-0"], reservedSlots["ide-1-0"] = true, true
+	}
+	if _, ok := d.GetOk("disk"); ok {
+		reservedSlots["sata-0-0"] = true
+	}
+	if _, ok := d.GetOk("clone_from"); ok {
+		reservedSlots["sata-0-0"] = true
+	}
+	if !cloudInitSeed(d).Empty() {
+		reservedSlots[pkg.SeedSlot] = true
+	}
+
+	for i, disk := range expandStorage(d.Get("storage").([]interface{}), "", "") {
+		badDisk := ""
+		switch disk.Format {
+		case "vdi", "vmdk", "vhd":
+		default:
+			badDisk += "\tformat does not match any of the existing ones (vdi | vmdk | vhd)\n"
+		}
+
+		if pkg.ControllerName(disk.Controller) == "" {
+			badDisk += fmt.Sprintf("\tcontroller does not match any of the existing ones (%s)\n", strings.Join(pkg.ControllerTypes(), " | "))
+		}
+
+		if disk.HotPluggable && disk.Controller != "sata" {
+			badDisk += "\tonly sata disks can be hot-pluggable\n"
+		}
+
+		if disk.DiskID == "" && disk.SizeMB <= 0 {
+			badDisk += "\tsize must be greater than 0\n"
+		}
+
+		if reservedSlots[disk.Slot()] {
+			badDisk += fmt.Sprintf("\tslot %s is already used by the VM disk or image\n", disk.Slot())
+		}
+		reservedSlots[disk.Slot()] = true
+
+		if badDisk != "" {
+			error_output = append(error_output, fmt.Sprintf("Storage %d:\n%s", i, badDisk))
+			amountOfProblems++
+		}
+	}
+
+	if d.Get("secure_boot").(bool) && d.Get("firmware").(string) == "bios" {
+		error_output = append(error_output, "Secure boot requires efi or efi64 firmware")
+		amountOfProblems++
+	}
+
+	// Checking shared folders
+	folderNames := map[string]bool{}
+	for i, folder := range expandSharedFolders(d.Get("shared_folder").([]interface{})) {
+		if folderNames[folder.Name] {
+			error_output = append(error_output, fmt.Sprintf("Shared folder %d: name %s is already used", i, folder.Name))
+			amountOfProblems++
+		}
+		folderNames[folder.Name] = true
+
+		if !filepath.IsAbs(folder.HostPath) {
+			error_output = append(error_output, fmt.Sprintf("Shared folder %d: host_path must be absolute", i))
+			amountOfProblems++
+		}
+
+		if folder.Transient && d.Get("status").(string) != "running" {
+			error_output = append(error_output, fmt.Sprintf("Shared folder %d: transient folder requires status running", i))
+			amountOfProblems++
+		}
+	}
+
 	status := d.Get("status").(string)
 	switch status {
 	case "poweroff":
@@ -1175,6 +2896,8 @@ func validateVmParams(d *schema.ResourceData, isCreate bool) error {
 			return nil
 		case "hostonly":
 			return nil
+		case "hostonlynet":
+			return nil
 		case "generic":
 			return nil
 		default:
@@ -1243,6 +2966,32 @@ func validateVmParams(d *schema.ResourceData, isCreate bool) error {
 		error_output = append(error_output, allNICReports)
 	}
 
+	// NAT engine is only used by adapters in nat mode, its settings on other adapters would be ignored
+	for i := 0; i < amountOfNICs; i++ {
+		if d.Get(fmt.Sprintf("network_adapter.%d.network_mode", i)).(string) == "nat" {
+			continue
+		}
+		if !expandNATSettings(d, i).IsDefault() {
+			amountOfProblems++
+			error_output = append(error_output, fmt.Sprintf("NIC %d: nat_network, dns_proxy, dns_host_resolver, alias_mode, tftp_prefix, tftp_file and bind_ip can only be set in nat mode", i))
+		}
+	}
+
+	// Adapters with the same MAC address can't be told apart by DHCP servers and switches
+	macs := make(map[string]int)
+	for i := 0; i < amountOfNICs; i++ {
+		mac := pkg.NormalizeMAC(d.Get(fmt.Sprintf("network_adapter.%d.mac_address", i)).(string))
+		if mac == "" {
+			continue
+		}
+		if j, ok := macs[mac]; ok {
+			amountOfProblems++
+			error_output = append(error_output, fmt.Sprintf("NIC %d and NIC %d have the same mac_address %s", j, i, pkg.FormatMAC(mac)))
+			continue
+		}
+		macs[mac] = i
+	}
+
 	if amountOfProblems == 0 {
 		return nil
 	}
@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// ImportAppliance imports first virtual system of OVA/OVF appliance as VM and registers it
+// name, group and OS type of VM override the ones from appliance, empty OS type keeps the one of appliance
+func ImportAppliance(vb *vbg.VBox, vm *vbg.VirtualMachine, path string) error {
+	args := []string{"import", path, "--vsys", "0",
+		"--vmname", vm.Spec.Name,
+		"--basefolder", vb.Config.BasePath}
+	if vm.Spec.OSType.ID != "" {
+		args = append(args, "--ostype", vm.Spec.OSType.ID)
+	}
+	if vm.Spec.Group != "" {
+		args = append(args, "--group", vm.Spec.Group)
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("import of appliance %s failed: %s", path, err.Error())
+	}
+	return nil
+}
+
+// ExportOptions holds settings of exported appliance, metadata is applied to every virtual system
+type ExportOptions struct {
+	OVFVersion  string
+	Manifest    bool
+	Product     string
+	ProductURL  string
+	Vendor      string
+	VendorURL   string
+	Version     string
+	Description string
+}
+
+// ovfVersions maps version of OVF to "export" option
+var ovfVersions = map[string]string{
+	"0.9": "--ovf09",
+	"1.0": "--ovf10",
+	"2.0": "--ovf20",
+}
+
+// OVFVersions returns supported versions of OVF
+func OVFVersions() []string {
+	return []string{"0.9", "1.0", "2.0"}
+}
+
+// ExportAppliance exports VMs to OVA/OVF appliance at path, VMs must not be running
+func ExportAppliance(vb *vbg.VBox, vms []string, path string, opts ExportOptions) error {
+	args := append([]string{"export"}, vms...)
+	args = append(args, "--output", path)
+
+	if opts.OVFVersion != "" {
+		version, ok := ovfVersions[opts.OVFVersion]
+		if !ok {
+			return fmt.Errorf("unknown OVF version %s", opts.OVFVersion)
+		}
+		args = append(args, version)
+	}
+	if opts.Manifest {
+		args = append(args, "--manifest")
+	}
+
+	metadata := [][2]string{
+		{"--product", opts.Product},
+		{"--producturl", opts.ProductURL},
+		{"--vendor", opts.Vendor},
+		{"--vendorurl", opts.VendorURL},
+		{"--version", opts.Version},
+		{"--description", opts.Description},
+	}
+	for i := range vms {
+		args = append(args, "--vsys", strconv.Itoa(i))
+		for _, option := range metadata {
+			if option[1] != "" {
+				args = append(args, option[0], option[1])
+			}
+		}
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("export of appliance %s failed: %s", path, err.Error())
+	}
+	return nil
+}
+
+// ApplianceFiles returns files written by export of appliance at path:
+// OVA itself, or OVF with its manifest and disks
+func ApplianceFiles(path string) ([]string, error) {
+	if filepath.Ext(path) != ".ovf" {
+		return []string{path}, nil
+	}
+
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	disks, err := filepath.Glob(base + "-disk*.vmdk")
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{path, base + ".mf"}, disks...), nil
+}
+
+// volatileVMKeys are "showvminfo" keys that change without changing VM itself
+var volatileVMKeys = []string{"VMState", "VMStateChangeTime", "VMStateFile", "GuestAdditions", "GuestOSType", "SessionName", "VideoMode"}
+
+// VMFingerprint returns hash of VM settings, UUIDs of attached media and current snapshot,
+// it changes whenever VM is reconfigured, snapshotted or its disks are replaced.
+// Disk content is not hashed, running guest writes its disks all the time
+func VMFingerprint(vb *vbg.VBox, uuidOrName string) (string, error) {
+	info, err := VMInfoMap(vb, uuidOrName)
+	if err != nil {
+		return "", err
+	}
+
+	keys := make([]string, 0, len(info))
+	for key := range info {
+		volatile := false
+		for _, prefix := range volatileVMKeys {
+			if strings.HasPrefix(key, prefix) {
+				volatile = true
+				break
+			}
+		}
+		if !volatile {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s=%s\n", key, info[key])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,225 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// lockHeartbeat is how often holder of lock refreshes it
+	lockHeartbeat = 30 * time.Second
+	// lockStale is age after which lock is considered left by crashed process
+	lockStale = 4 * lockHeartbeat
+	// lockPoll is how often waiter checks whether lock is released
+	lockPoll = 200 * time.Millisecond
+)
+
+// ImageCache stores files downloaded by url, so that VMs using the same url download it once
+// every file lives in its own entry directory keyed by url and checksum
+type ImageCache struct {
+	Dir string
+	// MaxSizeMB is total size of cache after which least recently used entries are evicted, 0 means unlimited
+	MaxSizeMB int64
+	// MaxAge is time since last use after which entry is evicted, 0 means unlimited
+	MaxAge time.Duration
+}
+
+// cacheKey returns name of cache entry for url and checksum
+func cacheKey(url, checksum string) string {
+	h := sha256.Sum256([]byte(url + "\n" + checksum))
+	return hex.EncodeToString(h[:16])
+}
+
+// Fetch returns path to file from url, downloading it into cache if needed
+// entry stays locked until release is called, so file can be converted and copied by caller
+// while concurrent fetches of the same url wait, even from different processes, and eviction skips it
+// release can be called more than once
+func (c *ImageCache) Fetch(url, checksum string) (file string, release func(), err error) {
+	key := cacheKey(url, checksum)
+	entry := filepath.Join(c.Dir, key)
+
+	if err := os.MkdirAll(c.Dir, 0740); err != nil {
+		return "", nil, fmt.Errorf("creation cache foldier failed: %s", err.Error())
+	}
+
+	unlock, err := lockFile(entry + ".lock")
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		if err != nil {
+			unlock()
+		}
+	}()
+
+	if err := os.MkdirAll(entry, 0740); err != nil {
+		return "", nil, fmt.Errorf("creation cache entry failed: %s", err.Error())
+	}
+
+	// File without checksum is trusted once it was downloaded completely,
+	// file with checksum is verified by FileDownload
+	file = filepath.Join(entry, urlFileName(url))
+	if _, statErr := os.Stat(file); statErr != nil || checksum != "" {
+		if file, err = FileDownload(url, entry, checksum); err != nil {
+			return "", nil, err
+		}
+	} else {
+		logrus.Printf("Using cached file %s\n", file)
+	}
+
+	// Modification time of entry is time of its last use
+	now := time.Now()
+	if err = os.Chtimes(entry, now, now); err != nil {
+		return "", nil, err
+	}
+
+	if err := c.Evict(key); err != nil {
+		logrus.Warnf("Cache eviction failed: %s", err.Error())
+	}
+
+	var once sync.Once
+	return file, func() { once.Do(unlock) }, nil
+}
+
+// cacheEntry is directory of cache with its size and time of last use
+type cacheEntry struct {
+	key     string
+	size    int64
+	modTime time.Time
+}
+
+// Evict removes entries unused for longer than MaxAge, then least recently used ones
+// until cache fits into MaxSizeMB, entry keep and entries being downloaded are never removed
+func (c *ImageCache) Evict(keep string) error {
+	if c.MaxSizeMB <= 0 && c.MaxAge <= 0 {
+		return nil
+	}
+
+	dirs, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+
+	var entries []cacheEntry
+	var total int64
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		info, err := dir.Info()
+		if err != nil {
+			continue
+		}
+
+		entry := cacheEntry{key: dir.Name(), modTime: info.ModTime()}
+		filepath.WalkDir(filepath.Join(c.Dir, dir.Name()), func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				if info, err := d.Info(); err == nil {
+					entry.size += info.Size()
+				}
+			}
+			return nil
+		})
+
+		total += entry.size
+		entries = append(entries, entry)
+	}
+
+	// Least recently used first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	for _, entry := range entries {
+		expired := c.MaxAge > 0 && time.Since(entry.modTime) > c.MaxAge
+		oversized := c.MaxSizeMB > 0 && total > c.MaxSizeMB*1024*1024
+		if !expired && !oversized {
+			continue
+		}
+		if entry.key == keep {
+			continue
+		}
+
+		// Entry used by someone else right now is skipped
+		lock := filepath.Join(c.Dir, entry.key+".lock")
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+		if err != nil {
+			continue
+		}
+		f.Close()
+
+		logrus.Infof("Evicting cache entry %s", entry.key)
+		err = os.RemoveAll(filepath.Join(c.Dir, entry.key))
+		os.Remove(lock)
+		if err != nil {
+			return err
+		}
+		total -= entry.size
+	}
+
+	return nil
+}
+
+// lockFile takes exclusive lock by creating file at path, waiting while other holder has it
+// lock is refreshed while it is held, so that lock of crashed process expires after lockStale
+func lockFile(path string) (func(), error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("lock %s failed: %s", path, err.Error())
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockStale {
+			logrus.Warnf("Removing stale lock %s", path)
+			os.Remove(path)
+			continue
+		}
+
+		logrus.Debugf("Waiting for lock %s", path)
+		time.Sleep(lockPoll)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				os.Chtimes(path, now, now)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		os.Remove(path)
+	}, nil
+}
+
+// DefaultCacheDir returns default directory of image cache inside user cache directory,
+// or inside fallback if user has none
+func DefaultCacheDir(fallback string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil || dir == "" {
+		dir = fallback
+	}
+	return filepath.Join(dir, "terraform-provider-virtualbox", "images")
+}
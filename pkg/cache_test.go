@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_ImageCacheFetch(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, strings.Repeat("x", 1024))
+	}))
+	defer server.Close()
+
+	cache := &ImageCache{Dir: t.TempDir()}
+
+	var wg sync.WaitGroup
+	paths := make([]string, 5)
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			path, release, err := cache.Fetch(server.URL+"/image.iso", "")
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err.Error())
+				return
+			}
+			defer release()
+			paths[i] = path
+		}(i)
+	}
+	wg.Wait()
+
+	if requests != 1 {
+		t.Errorf("Expected 1 download, actual %d", requests)
+	}
+	for _, path := range paths[1:] {
+		if path != paths[0] {
+			t.Errorf("Expected the same file %s, actual %s", paths[0], path)
+		}
+	}
+}
+
+func Test_ImageCacheFetchLocksEntry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", 1024))
+	}))
+	defer server.Close()
+
+	cache := &ImageCache{Dir: t.TempDir()}
+
+	_, release, err := cache.Fetch(server.URL+"/image.iso", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	// Second fetch waits until first user releases entry
+	var released atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, release, err := cache.Fetch(server.URL+"/image.iso", "")
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err.Error())
+			return
+		}
+		defer release()
+		if !released.Load() {
+			t.Errorf("Entry was fetched while it was in use")
+		}
+	}()
+
+	time.Sleep(3 * lockPoll)
+	released.Store(true)
+	release()
+	release()
+	<-done
+}
+
+func Test_ImageCacheEvict(t *testing.T) {
+	cache := &ImageCache{Dir: t.TempDir(), MaxSizeMB: 1}
+
+	// Three entries of 512 KB used at different times, the oldest one exceeds size limit
+	for i, key := range []string{"old", "middle", "new"} {
+		entry := filepath.Join(cache.Dir, key)
+		if err := os.MkdirAll(entry, 0740); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(entry, "image.iso"), make([]byte, 512*1024), 0640); err != nil {
+			t.Fatal(err)
+		}
+		used := time.Now().Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(entry, used, used); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cache.Evict("new"); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	for key, exists := range map[string]bool{"old": false, "middle": true, "new": true} {
+		if _, err := os.Stat(filepath.Join(cache.Dir, key)); (err == nil) != exists {
+			t.Errorf("Entry %s: expected existence %v", key, exists)
+		}
+	}
+}
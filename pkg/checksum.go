@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Checksum is expected hash of downloaded file
+type Checksum struct {
+	Algorithm string
+	Hash      string
+}
+
+// newHash returns hash function of checksum algorithm
+func (c Checksum) newHash() (hash.Hash, error) {
+	switch c.Algorithm {
+	case "md5":
+		return md5.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unknown checksum algorithm %s", c.Algorithm)
+}
+
+// algorithmByLength returns algorithm whose hex digest has given length
+func algorithmByLength(length int) string {
+	switch length {
+	case 32:
+		return "md5"
+	case 64:
+		return "sha256"
+	case 128:
+		return "sha512"
+	}
+	return ""
+}
+
+// ParseChecksum parses checksum of file downloaded from url, checksum is one of
+// "sha256:<hex>", "sha512:<hex>", "md5:<hex>" or "file:<url of SHA256SUMS-like file>"
+// empty checksum means that file is not verified
+func ParseChecksum(checksum string, url string) (*Checksum, error) {
+	if checksum == "" {
+		return nil, nil
+	}
+
+	algorithm, value, ok := strings.Cut(checksum, ":")
+	if !ok {
+		return nil, fmt.Errorf("checksum %s must look like <algorithm>:<hash> or file:<url>", checksum)
+	}
+
+	if algorithm == "file" {
+		return checksumFromFile(value, urlFileName(url))
+	}
+
+	c := &Checksum{Algorithm: strings.ToLower(algorithm), Hash: strings.ToLower(value)}
+	if _, err := c.newHash(); err != nil {
+		return nil, err
+	}
+	if algorithmByLength(len(c.Hash)) != c.Algorithm {
+		return nil, fmt.Errorf("%s is not a valid %s hash", c.Hash, c.Algorithm)
+	}
+	return c, nil
+}
+
+// checksumFromFile looks up checksum of file in checksums file at sumsURL
+// lines of checksums file look like "<hex>  <file>" or "<hex> *<file>"
+func checksumFromFile(sumsURL string, file string) (*Checksum, error) {
+	resp, err := http.Get(sumsURL)
+	if err != nil {
+		return nil, fmt.Errorf("http get failed: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download of checksums file %s failed: %s", sumsURL, resp.Status)
+	}
+
+	s := bufio.NewScanner(resp.Body)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(fields[1], "*"), "./")
+		if name != file {
+			continue
+		}
+
+		algorithm := algorithmByLength(len(fields[0]))
+		if algorithm == "" {
+			return nil, fmt.Errorf("unknown checksum %s of %s in %s", fields[0], file, sumsURL)
+		}
+		return &Checksum{Algorithm: algorithm, Hash: strings.ToLower(fields[0])}, nil
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading checksums file failed: %s", err.Error())
+	}
+
+	return nil, fmt.Errorf("checksum of %s not found in %s", file, sumsURL)
+}
+
+// Verify checks that file at fpath matches checksum
+func (c Checksum) Verify(fpath string) error {
+	h, err := c.newHash()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("reading %s failed: %s", fpath, err.Error())
+	}
+
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != c.Hash {
+		return fmt.Errorf("%s checksum mismatch for %s: expected %s, actual %s", c.Algorithm, fpath, c.Hash, actual)
+	}
+	return nil
+}
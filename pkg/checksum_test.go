@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ParseChecksum(t *testing.T) {
+	hash := strings.Repeat("a", 64)
+
+	sum, err := ParseChecksum("sha256:"+strings.ToUpper(hash), "http://example.com/image.iso")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if sum.Algorithm != "sha256" || sum.Hash != hash {
+		t.Errorf("Expected sha256 %s, actual: %v", hash, sum)
+	}
+
+	if _, err := ParseChecksum("sha512:"+hash, "http://example.com/image.iso"); err == nil {
+		t.Errorf("Expected error for sha512 of wrong length")
+	}
+
+	if _, err := ParseChecksum(hash, "http://example.com/image.iso"); err == nil {
+		t.Errorf("Expected error for checksum without algorithm")
+	}
+
+	sums := "# comment\n" +
+		strings.Repeat("b", 32) + "  other.iso\n" +
+		hash + " *image.iso\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, sums)
+	}))
+	defer server.Close()
+
+	// Query and fragment of signed urls are not part of file name
+	for _, url := range []string{"http://example.com/image.iso", "http://example.com/image.iso?X-Signature=abc&expires=1#top"} {
+		sum, err = ParseChecksum("file:"+server.URL+"/SHA256SUMS", url)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", url, err.Error())
+		}
+		if sum.Algorithm != "sha256" || sum.Hash != hash {
+			t.Errorf("Expected sha256 %s for %s, actual: %v", hash, url, sum)
+		}
+	}
+}
+
+func Test_FileDownloadResume(t *testing.T) {
+	content := strings.Repeat("image data ", 1000)
+	h := sha256.Sum256([]byte(content))
+	checksum := "sha256:" + hex.EncodeToString(h[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.iso" {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<html>not found</html>")
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "image.iso", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	// Half of file is left from interrupted download
+	if err := os.WriteFile(filepath.Join(dir, "image.iso.part"), []byte(content[:len(content)/2]), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := FileDownload(server.URL+"/image.iso", dir, checksum)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("Downloaded file differs from served one")
+	}
+
+	// Part which can't be verified is downloaded again
+	unverified := t.TempDir()
+	if err := os.WriteFile(filepath.Join(unverified, "image.iso.part"), []byte("stale data"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	path, err = FileDownload(server.URL+"/image.iso", unverified, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("Stale part must not be resumed without checksum")
+	}
+
+	if _, err := FileDownload(server.URL+"/missing.iso", dir, ""); err == nil {
+		t.Errorf("Expected error for missing file")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.iso")); !os.IsNotExist(err) {
+		t.Errorf("Missing file must not be saved")
+	}
+
+	if _, err := FileDownload(server.URL+"/image.iso", t.TempDir(), "sha256:"+strings.Repeat("0", 64)); err == nil {
+		t.Errorf("Expected checksum mismatch")
+	}
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	vbg "github.com/mixdone/virtualbox-go"
+	"github.com/sirupsen/logrus"
+)
+
+// Seed image is attached to port 1 device 1 of IDE controller, next to image loaded with "image" or "url"
+const (
+	SeedSlot   = "ide-1-1"
+	seedPort   = "1"
+	seedDevice = "1"
+)
+
+// CloudInitSeed is content of NoCloud seed image read by cloud-init in guest
+type CloudInitSeed struct {
+	UserData      string
+	MetaData      string
+	NetworkConfig string
+}
+
+// Empty checks whether seed has nothing to pass to guest
+func (s CloudInitSeed) Empty() bool {
+	return s.UserData == "" && s.MetaData == "" && s.NetworkConfig == ""
+}
+
+// Files returns files of seed image, meta-data with VM UUID as instance-id and VM name as hostname is used by default
+func (s CloudInitSeed) Files(vmUUID, vmName string) map[string][]byte {
+	metaData := s.MetaData
+	if metaData == "" {
+		metaData = fmt.Sprintf("instance-id: %s\nlocal-hostname: %s\n", vmUUID, vmName)
+	}
+
+	files := map[string][]byte{
+		"user-data": []byte(s.UserData),
+		"meta-data": []byte(metaData),
+	}
+	if s.NetworkConfig != "" {
+		files["network-config"] = []byte(s.NetworkConfig)
+	}
+	return files
+}
+
+// SeedPath returns location of seed image inside folder of VM, so that it is removed together with VM
+func SeedPath(info map[string]string) string {
+	return filepath.Join(filepath.Dir(info["CfgFile"]), "cidata.iso")
+}
+
+// AttachSeed writes seed image of VM and attaches it as DVD
+func AttachSeed(vb *vbg.VBox, vm *vbg.VirtualMachine, seed CloudInitSeed) error {
+	info, err := VMInfoMap(vb, vm.UUIDOrName())
+	if err != nil {
+		return err
+	}
+
+	path := SeedPath(info)
+	if err := WriteISO(path, "cidata", seed.Files(info["UUID"], info["name"])); err != nil {
+		return fmt.Errorf("writing seed image failed: %s", err.Error())
+	}
+
+	if err := EnsureController(vb, vm, "ide"); err != nil {
+		return fmt.Errorf("add ide controller error: %s", err.Error())
+	}
+
+	if _, err := Manage(vb, "storageattach", vm.UUIDOrName(),
+		"--storagectl", ControllerName("ide"),
+		"--port", seedPort,
+		"--device", seedDevice,
+		"--type", "dvddrive",
+		"--medium", path); err != nil {
+		return fmt.Errorf("attach error: %s", err.Error())
+	}
+
+	return nil
+}
+
+// DetachSeed detaches seed image of VM and removes it, nothing is done if VM has no seed image
+func DetachSeed(vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	info, err := VMInfoMap(vb, vm.UUIDOrName())
+	if err != nil {
+		return err
+	}
+
+	path := SeedPath(info)
+	if attached, _ := AttachedDisk(info, StorageDisk{Controller: "ide", Port: 1, Device: 1}); attached != "" {
+		if _, err := Manage(vb, "storageattach", vm.UUIDOrName(),
+			"--storagectl", ControllerName("ide"),
+			"--port", seedPort,
+			"--device", seedDevice,
+			"--medium", "none"); err != nil {
+			return fmt.Errorf("detach error: %s", err.Error())
+		}
+	}
+
+	// Medium is closed, so that rewritten image is not confused with registered one
+	if _, err := os.Stat(path); err == nil {
+		if _, err := Manage(vb, "closemedium", "dvd", path); err != nil {
+			logrus.Warnf("Unable to close seed image %s: %s", path, err.Error())
+		}
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ConvertImage converts qcow2 and raw disk images to dynamic VDI placed next to the source,
+// so that converted image is cached together with downloaded one
+// path to VDI is returned, images of other formats are returned as is
+func ConvertImage(path string) (string, error) {
+	format, err := DetectImage(path)
+	if err != nil {
+		return "", err
+	}
+	if format != "qcow2" && format != "raw" {
+		return path, nil
+	}
+
+	dest := strings.TrimSuffix(path, filepath.Ext(path)) + ".vdi"
+	if _, err := os.Stat(dest); err == nil {
+		logrus.Printf("Using converted image %s\n", dest)
+		return dest, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var src io.ReaderAt = f
+	var size int64
+	if format == "qcow2" {
+		q, err := openQcow2(f)
+		if err != nil {
+			return "", fmt.Errorf("reading qcow2 image %s failed: %s", path, err.Error())
+		}
+		src, size = q, q.size
+	} else {
+		info, err := f.Stat()
+		if err != nil {
+			return "", err
+		}
+		size = info.Size()
+	}
+
+	logrus.Printf("Converting %s image %s to VDI\n", format, path)
+
+	// Converted image appears only when it is complete, every conversion writes its own file,
+	// so that parallel conversions of the same image don't overwrite each other
+	part, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.part")
+	if err != nil {
+		return "", err
+	}
+	part.Chmod(0640)
+	part.Close()
+	if err := writeVDI(src, size, part.Name()); err != nil {
+		os.Remove(part.Name())
+		return "", fmt.Errorf("conversion of %s failed: %s", path, err.Error())
+	}
+	if err := os.Rename(part.Name(), dest); err != nil {
+		os.Remove(part.Name())
+		return "", err
+	}
+
+	return dest, nil
+}
+
+// qcow2Image reads guest data of qcow2 image without backing file, encryption or external data file
+type qcow2Image struct {
+	f           *os.File
+	size        int64
+	clusterBits uint32
+	clusterSize int64
+	l1          []uint64
+
+	// last read L2 table and cluster, data is usually read sequentially
+	l2Offset      uint64
+	l2            []uint64
+	clusterIndex  int64
+	cluster       []byte
+	clusterCached bool
+}
+
+const (
+	qcow2OffsetMask     = 0x00fffffffffffe00
+	qcow2Compressed     = 1 << 62
+	qcow2ZeroCluster    = 1
+	qcow2IncompatDirty  = 1 << 0
+	qcow2IncompatCompr  = 1 << 3
+	qcow2IncompatKnown  = qcow2IncompatDirty | qcow2IncompatCompr
+	qcow2MaxClusterBits = 21
+)
+
+// openQcow2 parses header and L1 table of qcow2 image
+func openQcow2(f *os.File) (*qcow2Image, error) {
+	header := make([]byte, 105)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && !(err == io.EOF && n >= 72) {
+		return nil, err
+	}
+	be := binary.BigEndian
+
+	if string(header[:4]) != "QFI\xfb" {
+		return nil, fmt.Errorf("not a qcow2 image")
+	}
+	version := be.Uint32(header[4:])
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported qcow2 version %d", version)
+	}
+	if be.Uint64(header[8:]) != 0 {
+		return nil, fmt.Errorf("images with backing file are not supported")
+	}
+	if be.Uint32(header[32:]) != 0 {
+		return nil, fmt.Errorf("encrypted images are not supported")
+	}
+
+	if version == 3 {
+		incompatible := be.Uint64(header[72:])
+		if incompatible&^qcow2IncompatKnown != 0 {
+			return nil, fmt.Errorf("unsupported qcow2 features %#x", incompatible&^qcow2IncompatKnown)
+		}
+		if incompatible&qcow2IncompatCompr != 0 && be.Uint32(header[100:]) > 104 && header[104] != 0 {
+			return nil, fmt.Errorf("only deflate compression is supported")
+		}
+	}
+
+	q := &qcow2Image{
+		f:           f,
+		clusterBits: be.Uint32(header[20:]),
+		size:        int64(be.Uint64(header[24:])),
+	}
+	if q.clusterBits < 9 || q.clusterBits > qcow2MaxClusterBits {
+		return nil, fmt.Errorf("wrong cluster bits %d", q.clusterBits)
+	}
+	q.clusterSize = 1 << q.clusterBits
+
+	l1Size := be.Uint32(header[36:])
+	raw := make([]byte, int(l1Size)*8)
+	if _, err := f.ReadAt(raw, int64(be.Uint64(header[40:]))); err != nil {
+		return nil, fmt.Errorf("reading L1 table failed: %s", err.Error())
+	}
+	q.l1 = make([]uint64, l1Size)
+	for i := range q.l1 {
+		q.l1[i] = be.Uint64(raw[i*8:])
+	}
+
+	q.cluster = make([]byte, q.clusterSize)
+	return q, nil
+}
+
+// readCluster reads guest cluster with given index into q.cluster
+func (q *qcow2Image) readCluster(index int64) error {
+	if q.clusterCached && q.clusterIndex == index {
+		return nil
+	}
+	q.clusterCached = false
+
+	zero := func() error {
+		for i := range q.cluster {
+			q.cluster[i] = 0
+		}
+		q.clusterIndex, q.clusterCached = index, true
+		return nil
+	}
+
+	l2Entries := q.clusterSize / 8
+	l1Index := index / l2Entries
+	if l1Index >= int64(len(q.l1)) {
+		return zero()
+	}
+
+	l2Offset := q.l1[l1Index] & qcow2OffsetMask
+	if l2Offset == 0 {
+		return zero()
+	}
+
+	if q.l2 == nil || q.l2Offset != l2Offset {
+		raw := make([]byte, q.clusterSize)
+		if _, err := q.f.ReadAt(raw, int64(l2Offset)); err != nil {
+			return fmt.Errorf("reading L2 table failed: %s", err.Error())
+		}
+		q.l2 = make([]uint64, l2Entries)
+		for i := range q.l2 {
+			q.l2[i] = binary.BigEndian.Uint64(raw[i*8:])
+		}
+		q.l2Offset = l2Offset
+	}
+
+	entry := q.l2[index%l2Entries]
+
+	if entry&qcow2Compressed != 0 {
+		// Compressed cluster descriptor: host offset in low x bits, then number of additional 512 byte sectors
+		x := 62 - (q.clusterBits - 8)
+		offset := entry & (1<<x - 1)
+		sectors := (entry>>x)&(1<<(q.clusterBits-8)-1) + 1
+		size := int64(sectors)*512 - int64(offset&511)
+
+		compressed := make([]byte, size)
+		n, err := q.f.ReadAt(compressed, int64(offset))
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("reading compressed cluster failed: %s", err.Error())
+		}
+
+		r := flate.NewReader(bytes.NewReader(compressed[:n]))
+		defer r.Close()
+		if _, err := io.ReadFull(r, q.cluster); err != nil {
+			return fmt.Errorf("decompressing cluster failed: %s", err.Error())
+		}
+		q.clusterIndex, q.clusterCached = index, true
+		return nil
+	}
+
+	offset := entry & qcow2OffsetMask
+	if entry&qcow2ZeroCluster != 0 || offset == 0 {
+		return zero()
+	}
+
+	if _, err := q.f.ReadAt(q.cluster, int64(offset)); err != nil && err != io.EOF {
+		return fmt.Errorf("reading cluster failed: %s", err.Error())
+	}
+	q.clusterIndex, q.clusterCached = index, true
+	return nil
+}
+
+// ReadAt reads guest data of image
+func (q *qcow2Image) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for read < len(p) {
+		if off >= q.size {
+			return read, io.EOF
+		}
+		if err := q.readCluster(off / q.clusterSize); err != nil {
+			return read, err
+		}
+
+		n := copy(p[read:], q.cluster[off%q.clusterSize:])
+		if remaining := q.size - off; int64(n) > remaining {
+			n = int(remaining)
+		}
+		read += n
+		off += int64(n)
+	}
+	return read, nil
+}
+
+const (
+	vdiBlockSize = 1024 * 1024
+	vdiAlign     = 1024 * 1024
+	vdiHeaderOff = 72
+	vdiBlockFree = 0xffffffff
+	vdiSignature = 0xbeda107f
+	vdiVersion   = 0x00010001
+)
+
+// writeVDI writes guest data of size bytes read from src as dynamic VDI at dest,
+// blocks containing only zeros are not allocated
+func writeVDI(src io.ReaderAt, size int64, dest string) error {
+	// Disk size must be multiple of sector
+	size = (size + 511) / 512 * 512
+	blocks := (size + vdiBlockSize - 1) / vdiBlockSize
+
+	offBlocks := int64(vdiAlign)
+	offData := (offBlocks + blocks*4 + vdiAlign - 1) / vdiAlign * vdiAlign
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	blockMap := make([]uint32, blocks)
+	allocated := uint32(0)
+	buf := make([]byte, vdiBlockSize)
+	for i := int64(0); i < blocks; i++ {
+		for j := range buf {
+			buf[j] = 0
+		}
+		n, err := src.ReadAt(buf, i*vdiBlockSize)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n == 0 || isZero(buf[:n]) {
+			blockMap[i] = vdiBlockFree
+			continue
+		}
+
+		if _, err := out.WriteAt(buf, offData+int64(allocated)*vdiBlockSize); err != nil {
+			return err
+		}
+		blockMap[i] = allocated
+		allocated++
+	}
+
+	mapBuf := make([]byte, blocks*4)
+	for i, entry := range blockMap {
+		binary.LittleEndian.PutUint32(mapBuf[i*4:], entry)
+	}
+	if _, err := out.WriteAt(mapBuf, offBlocks); err != nil {
+		return err
+	}
+
+	header, err := vdiHeader(size, uint32(blocks), allocated, uint32(offBlocks), uint32(offData))
+	if err != nil {
+		return err
+	}
+	if _, err := out.WriteAt(header, 0); err != nil {
+		return err
+	}
+
+	// File must cover data area even if no block is allocated
+	if info, err := out.Stat(); err == nil && info.Size() < offData {
+		if err := out.Truncate(offData); err != nil {
+			return err
+		}
+	}
+
+	return out.Close()
+}
+
+// vdiHeader returns pre-header and header of VDI version 1.1
+func vdiHeader(size int64, blocks, allocated, offBlocks, offData uint32) ([]byte, error) {
+	header := make([]byte, vdiHeaderOff+400)
+	le := binary.LittleEndian
+
+	copy(header, "<<< Oracle VM VirtualBox Disk Image >>>\n")
+	le.PutUint32(header[64:], vdiSignature)
+	le.PutUint32(header[68:], vdiVersion)
+
+	h := header[vdiHeaderOff:]
+	le.PutUint32(h[0:], 400) // size of header
+	le.PutUint32(h[4:], 1)   // normal (dynamic) image
+	le.PutUint32(h[8:], 0)   // flags
+	// 256 bytes of comment
+	le.PutUint32(h[268:], offBlocks)
+	le.PutUint32(h[272:], offData)
+
+	// Legacy geometry: cylinders, heads, sectors, sector size
+	cylinders := size / 512 / 16 / 63
+	if cylinders > 16383 {
+		cylinders = 16383
+	}
+	le.PutUint32(h[276:], uint32(cylinders))
+	le.PutUint32(h[280:], 16)
+	le.PutUint32(h[284:], 63)
+	le.PutUint32(h[288:], 512)
+
+	le.PutUint64(h[296:], uint64(size))
+	le.PutUint32(h[304:], vdiBlockSize)
+	le.PutUint32(h[308:], 0) // extra data per block
+	le.PutUint32(h[312:], blocks)
+	le.PutUint32(h[316:], allocated)
+
+	// Creation and modification UUIDs, linkage UUIDs stay zero
+	if _, err := rand.Read(h[320:352]); err != nil {
+		return nil, err
+	}
+	for _, uuid := range [][]byte{h[320:336], h[336:352]} {
+		uuid[7] = uuid[7]&0x0f | 0x40 // version 4, stored little-endian
+		uuid[8] = uuid[8]&0x3f | 0x80
+	}
+
+	return header, nil
+}
+
+// isZero checks whether buf contains only zeros
+func isZero(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// readVDI returns guest data of dynamic VDI written by writeVDI
+func readVDI(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	le := binary.LittleEndian
+
+	if le.Uint32(data[64:]) != vdiSignature {
+		t.Fatalf("Wrong VDI signature")
+	}
+	h := data[vdiHeaderOff:]
+	offBlocks := int64(le.Uint32(h[268:]))
+	offData := int64(le.Uint32(h[272:]))
+	size := int64(le.Uint64(h[296:]))
+	blocks := int64(le.Uint32(h[312:]))
+
+	guest := make([]byte, blocks*vdiBlockSize)
+	for i := int64(0); i < blocks; i++ {
+		entry := le.Uint32(data[offBlocks+i*4:])
+		if entry == vdiBlockFree {
+			continue
+		}
+		start := offData + int64(entry)*vdiBlockSize
+		copy(guest[i*vdiBlockSize:], data[start:start+vdiBlockSize])
+	}
+	return guest[:size]
+}
+
+func Test_ConvertQcow2(t *testing.T) {
+	const clusterBits = 16
+	const clusterSize = 1 << clusterBits
+	const size = 3 * 1024 * 1024
+
+	expected := make([]byte, size)
+	plain := bytes.Repeat([]byte{0xab}, clusterSize)
+	copy(expected[1*clusterSize:], plain)
+	packed := bytes.Repeat([]byte("hello qcow2 "), clusterSize/12+1)[:clusterSize]
+	copy(expected[20*clusterSize:], packed)
+
+	var compressed bytes.Buffer
+	w, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	w.Write(packed)
+	w.Close()
+
+	// Clusters of file: header, L1 table, L2 table, plain data, compressed data
+	image := make([]byte, 4*clusterSize+compressed.Len())
+	be := binary.BigEndian
+	copy(image, "QFI\xfb")
+	be.PutUint32(image[4:], 3)
+	be.PutUint32(image[20:], clusterBits)
+	be.PutUint64(image[24:], size)
+	be.PutUint32(image[36:], 1)
+	be.PutUint64(image[40:], clusterSize)
+	be.PutUint32(image[100:], 104)
+
+	be.PutUint64(image[clusterSize:], 2*clusterSize)
+
+	l2 := image[2*clusterSize:]
+	be.PutUint64(l2[1*8:], 3*clusterSize)
+	x := uint64(62 - (clusterBits - 8))
+	sectors := uint64((compressed.Len() + 511) / 512)
+	be.PutUint64(l2[20*8:], 1<<62|(sectors-1)<<x|4*clusterSize)
+	// Zero flag of qcow2 v3 makes cluster read as zeros even with offset
+	be.PutUint64(l2[30*8:], 3*clusterSize|1)
+
+	copy(image[3*clusterSize:], plain)
+	copy(image[4*clusterSize:], compressed.Bytes())
+
+	src := filepath.Join(t.TempDir(), "cloud.img")
+	if err := os.WriteFile(src, image, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := ConvertImage(src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if dest != filepath.Join(filepath.Dir(src), "cloud.vdi") {
+		t.Errorf("Unexpected path of converted image %s", dest)
+	}
+
+	if !bytes.Equal(readVDI(t, dest), expected) {
+		t.Errorf("Converted image differs from source")
+	}
+}
+
+func Test_ConvertRaw(t *testing.T) {
+	expected := make([]byte, 2*vdiBlockSize+4096)
+	copy(expected[2*vdiBlockSize:], "last block")
+
+	src := filepath.Join(t.TempDir(), "disk.raw")
+	if err := os.WriteFile(src, expected, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	dest, err := ConvertImage(src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	if !bytes.Equal(readVDI(t, dest), expected) {
+		t.Errorf("Converted image differs from source")
+	}
+
+	// Zero blocks are not stored
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 3*vdiBlockSize {
+		t.Errorf("Expected sparse image, actual size %d", info.Size())
+	}
+}
+
+func Test_ConvertParallel(t *testing.T) {
+	expected := make([]byte, 2*vdiBlockSize)
+	copy(expected, "first block")
+
+	src := filepath.Join(t.TempDir(), "disk.raw")
+	if err := os.WriteFile(src, expected, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	dests := make([]string, 4)
+	for i := range dests {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dest, err := ConvertImage(src)
+			if err != nil {
+				t.Errorf("Unexpected error: %s", err.Error())
+			}
+			dests[i] = dest
+		}(i)
+	}
+	wg.Wait()
+
+	for _, dest := range dests {
+		if dest != "" && !bytes.Equal(readVDI(t, dest), expected) {
+			t.Errorf("Converted image differs from source")
+		}
+	}
+
+	// Temporary files of conversions are not left behind
+	parts, _ := filepath.Glob(filepath.Join(filepath.Dir(src), "*.part"))
+	if len(parts) != 0 {
+		t.Errorf("Unexpected temporary files %v", parts)
+	}
+}
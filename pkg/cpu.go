@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// CPUFeatures is CPU and acceleration settings of VM
+type CPUFeatures struct {
+	ExecutionCap         int
+	NestedVirtualization bool
+	PAE                  bool
+	LongMode             bool
+	HPET                 bool
+	ParavirtProvider     string
+	NestedPaging         bool
+	LargePages           bool
+}
+
+// ParavirtProviders returns supported paravirtualization interfaces
+func ParavirtProviders() []string {
+	return []string{"none", "default", "legacy", "minimal", "hyperv", "kvm"}
+}
+
+// ReadCPUFeatures returns CPU settings from "showvminfo --machinereadable" output
+func ReadCPUFeatures(info map[string]string) CPUFeatures {
+	execCap, err := strconv.Atoi(info["cpuexecutioncap"])
+	if err != nil {
+		execCap = 100
+	}
+
+	nested := info["nested-hw-virt"]
+	if nested == "" {
+		nested = info["nestedhwvirt"]
+	}
+
+	return CPUFeatures{
+		ExecutionCap:         execCap,
+		NestedVirtualization: isOn(nested),
+		PAE:                  isOn(info["pae"]),
+		LongMode:             isOn(info["longmode"]),
+		HPET:                 isOn(info["hpet"]),
+		ParavirtProvider:     strings.ToLower(info["paravirtprovider"]),
+		NestedPaging:         isOn(info["nestedpaging"]),
+		LargePages:           isOn(info["largepages"]),
+	}
+}
+
+// SetCPUFeatures applies CPU settings which differ from current ones to powered off VM
+func SetCPUFeatures(vb *vbg.VBox, vm *vbg.VirtualMachine, f CPUFeatures, current CPUFeatures) error {
+	args := []string{"modifyvm", vm.UUIDOrName()}
+	if f.ExecutionCap != current.ExecutionCap {
+		args = append(args, "--cpu-execution-cap", strconv.Itoa(f.ExecutionCap))
+	}
+	if f.NestedVirtualization != current.NestedVirtualization {
+		args = append(args, "--nested-hw-virt", onOff(f.NestedVirtualization))
+	}
+	if f.PAE != current.PAE {
+		args = append(args, "--pae", onOff(f.PAE))
+	}
+	if f.LongMode != current.LongMode {
+		args = append(args, "--long-mode", onOff(f.LongMode))
+	}
+	if f.HPET != current.HPET {
+		args = append(args, "--hpet", onOff(f.HPET))
+	}
+	if f.ParavirtProvider != current.ParavirtProvider {
+		args = append(args, "--paravirt-provider", f.ParavirtProvider)
+	}
+	if f.NestedPaging != current.NestedPaging {
+		args = append(args, "--nested-paging", onOff(f.NestedPaging))
+	}
+	if f.LargePages != current.LargePages {
+		args = append(args, "--large-pages", onOff(f.LargePages))
+	}
+
+	if len(args) == 2 {
+		return nil
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("modifyvm failed: %s", err.Error())
+	}
+	return nil
+}
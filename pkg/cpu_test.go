@@ -0,0 +1,27 @@
+package pkg
+
+import "testing"
+
+func Test_ReadCPUFeatures(t *testing.T) {
+	info := parseMachineReadable(`cpuexecutioncap=50
+nested-hw-virt="on"
+pae="off"
+longmode="on"
+hpet="off"
+paravirtprovider="KVM"
+nestedpaging="on"
+largepages="off"
+`)
+
+	expected := CPUFeatures{
+		ExecutionCap:         50,
+		NestedVirtualization: true,
+		LongMode:             true,
+		ParavirtProvider:     "kvm",
+		NestedPaging:         true,
+	}
+
+	if f := ReadCPUFeatures(info); f != expected {
+		t.Errorf("Expected %+v, got %+v", expected, f)
+	}
+}
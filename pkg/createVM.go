@@ -3,6 +3,7 @@ package pkg
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	vbg "github.com/mixdone/virtualbox-go"
 	"github.com/sirupsen/logrus"
@@ -10,13 +11,22 @@ import (
 
 type LoadingType int
 
-// now here 3 types of loading
+// now here 5 types of loading
 const (
 	vdiLoading LoadingType = iota
 	imageloading
 	empty
+	cloneloading
+	applianceloading
 )
 
+// CloneSource describes VM and its snapshot new VM is cloned from
+type CloneSource struct {
+	VM       string
+	Snapshot string
+	Linked   bool
+}
+
 type VMConfig struct {
 	Name        string
 	CPUs        int
@@ -31,6 +41,9 @@ type VMConfig struct {
 	NICs        []vbg.NIC
 	DragAndDrop string
 	Clipboard   string
+	Storage     []StorageDisk
+	Clone       CloneSource
+	Platform    Platform
 }
 
 // create VM with chosen loading type
@@ -65,11 +78,13 @@ func CreateVM(vmCfg VMConfig) (*vbg.VirtualMachine, error) {
 
 	disk_VDI := vbg.Disk{
 		Path:       disk,
-		Format:     vbg.DiskFormat(filepath.Ext(filepath.Base(disk))[1:]),
 		SizeMB:     vmCfg.DiskSize,
 		Type:       "hdd",
 		Controller: sata,
 	}
+	if disk != "" {
+		disk_VDI.Format = vbg.DiskFormat(filepath.Ext(filepath.Base(disk))[1:])
+	}
 
 	// Loader for the image
 	storageController2 := vbg.StorageController{
@@ -102,7 +117,7 @@ func CreateVM(vmCfg VMConfig) (*vbg.VirtualMachine, error) {
 		disks = []vbg.Disk{disk_VDI}
 	case imageloading:
 		disks = []vbg.Disk{disk_VDI, disk_ISO}
-	case empty:
+	case empty, cloneloading, applianceloading:
 		disks = []vbg.Disk{}
 	}
 
@@ -126,31 +141,55 @@ func CreateVM(vmCfg VMConfig) (*vbg.VirtualMachine, error) {
 
 	logrus.Infoln("Creating VM with CPU and memory", vm.Spec.CPU, vm.Spec.Memory)
 
-	if err := vb.CreateVM(vm); err != nil {
-		return nil, fmt.Errorf("VM creation failed: %s", err.Error())
+	if vmCfg.Ltype == cloneloading {
+		if err := CloneVM(vb, vm, vmCfg.Clone); err != nil {
+			return nil, fmt.Errorf("VM cloning failed: %s", err.Error())
+		}
+	} else if vmCfg.Ltype == applianceloading {
+		if err := ImportAppliance(vb, vm, vmCfg.Image_path); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := vb.CreateVM(vm); err != nil {
+			return nil, fmt.Errorf("VM creation failed: %s", err.Error())
+		}
+
+		if err := vb.RegisterVM(vm); err != nil {
+			return nil, fmt.Errorf("failed registering vm: %s", err.Error())
+		}
 	}
 
-	if err := vb.RegisterVM(vm); err != nil {
-		return nil, fmt.Errorf("failed registering vm: %s", err.Error())
+	// Set CPUs and memory, zero keeps settings of appliance or source VM
+	if vm.Spec.CPU.Count != 0 {
+		if err := vb.SetCPUCount(vm, vm.Spec.CPU.Count); err != nil {
+			return nil, fmt.Errorf("set CPU Count failed: %s", err.Error())
+		}
 	}
 
-	// Set CPUs and memory
-	if err := vb.SetCPUCount(vm, vm.Spec.CPU.Count); err != nil {
-		return nil, fmt.Errorf("set CPU Count failed: %s", err.Error())
+	if vm.Spec.Memory.SizeMB != 0 {
+		if err := vb.SetMemory(vm, vm.Spec.Memory.SizeMB); err != nil {
+			return nil, fmt.Errorf("set memory failed: %s", err.Error())
+		}
 	}
 
-	if err := vb.SetMemory(vm, vm.Spec.Memory.SizeMB); err != nil {
-		return nil, fmt.Errorf("set memory failed: %s", err.Error())
+	// Set firmware, boot order and chipset
+	if vmCfg.Platform.Firmware != "" {
+		if err := SetPlatform(vb, vm, vmCfg.Platform); err != nil {
+			return nil, fmt.Errorf("set platform failed: %s", err.Error())
+		}
 	}
 
 	if len(vm.Spec.NICs) > 0 {
 		if err := vb.ModifyVM(vm, []string{"network_adapter"}); err != nil {
 			return nil, fmt.Errorf("set network failed: %s", err.Error())
 		}
+		if err := SetHostOnlyNets(vb, vm); err != nil {
+			return nil, fmt.Errorf("set network failed: %s", err.Error())
+		}
 	}
 
-	// Connecting a disk to a virtual machine
-	if vmCfg.Ltype != empty {
+	// Connecting a disk to a virtual machine, clone and appliance already have their disks
+	if vmCfg.Ltype == vdiLoading || vmCfg.Ltype == imageloading {
 		if err := vb.AddStorageController(vm, storageController1); err != nil {
 			return nil, fmt.Errorf("add SATA controller error: %s", err.Error())
 		}
@@ -171,6 +210,13 @@ func CreateVM(vmCfg VMConfig) (*vbg.VirtualMachine, error) {
 		}
 	}
 
+	// Connecting data disks
+	for i := range vmCfg.Storage {
+		if err := AttachDisk(vb, vm, &vmCfg.Storage[i]); err != nil {
+			return nil, err
+		}
+	}
+
 	if vm.Spec.CurrentSnapshot.Name != "" {
 		err := vb.TakeSnapshot(vm, vm.Spec.CurrentSnapshot, false)
 		if err != nil {
@@ -180,3 +226,49 @@ func CreateVM(vmCfg VMConfig) (*vbg.VirtualMachine, error) {
 
 	return vm, nil
 }
+
+// CloneVM creates VM as full or linked clone of source VM and registers it
+// linked clone shares disks of source snapshot and stores only changes made after cloning,
+// OS type of source VM is kept unless vm has one
+func CloneVM(vb *vbg.VBox, vm *vbg.VirtualMachine, src CloneSource) error {
+	args := []string{"clonevm", src.VM, "--name", vm.Spec.Name, "--basefolder", vb.Config.BasePath, "--register"}
+	if vm.Spec.Group != "" {
+		args = append(args, "--groups", vm.Spec.Group)
+	}
+	if src.Snapshot != "" {
+		args = append(args, "--snapshot", src.Snapshot)
+	}
+	if src.Linked {
+		args = append(args, "--options", "link")
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return err
+	}
+
+	// Empty OS type keeps OS type of source VM
+	if vm.Spec.OSType.ID == "" {
+		return nil
+	}
+	return vb.ModifyVM(vm, []string{"ostype"})
+}
+
+// MachineMedia returns UUIDs of media attached to VM that are located in folder of VM,
+// e.g. differencing disks of linked clone
+func MachineMedia(info map[string]string) []string {
+	vmDir := filepath.Dir(info["CfgFile"])
+
+	var media []string
+	for key, uuid := range info {
+		// e.g "SATA Controller-ImageUUID-0-0"="..." for "SATA Controller-0-0"="/path/to/disk.vdi"
+		controller, slot, ok := strings.Cut(key, "-ImageUUID-")
+		if !ok {
+			continue
+		}
+		path := info[controller+"-"+slot]
+		if rel, err := filepath.Rel(vmDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			media = append(media, uuid)
+		}
+	}
+	return media
+}
@@ -0,0 +1,20 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func Test_MachineMedia(t *testing.T) {
+	info := map[string]string{
+		"CfgFile":                       "/home/user/VMs/clone/clone.vbox",
+		"SATA Controller-0-0":           "/home/user/VMs/clone/Snapshots/{1111}.vdi",
+		"SATA Controller-ImageUUID-0-0": "1111",
+		"SATA Controller-1-0":           "/home/user/Disks/data.vdi",
+		"SATA Controller-ImageUUID-1-0": "2222",
+	}
+
+	media := MachineMedia(info)
+	if len(media) != 1 || media[0] != "1111" {
+		t.Errorf("Expected [1111], actual: %v", media)
+	}
+}
@@ -0,0 +1,105 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// Display is graphics and remote desktop settings of VM
+type Display struct {
+	VideoMemory        int
+	GraphicsController string
+	Accelerate3D       bool
+	MonitorCount       int
+	VRDE               VRDE
+}
+
+// VRDE is remote desktop server of VM, Port may be list or range of ports e.g "5000-5050"
+type VRDE struct {
+	Enabled  bool
+	Port     string
+	Address  string
+	AuthType string
+}
+
+// GraphicsControllers returns supported graphics controllers
+func GraphicsControllers() []string {
+	return []string{"vmsvga", "vboxsvga", "vboxvga"}
+}
+
+// VRDEAuthTypes returns supported authentication types of remote desktop
+func VRDEAuthTypes() []string {
+	return []string{"null", "external", "guest"}
+}
+
+// ReadDisplay returns display settings from "showvminfo --machinereadable" output
+// and port remote desktop server listens on, 0 if it is not running
+func ReadDisplay(info map[string]string) (Display, int) {
+	vram, _ := strconv.Atoi(info["vram"])
+	monitors, err := strconv.Atoi(info["monitorcount"])
+	if err != nil {
+		monitors = 1
+	}
+
+	d := Display{
+		VideoMemory:        vram,
+		GraphicsController: strings.ToLower(info["graphicscontroller"]),
+		Accelerate3D:       isOn(info["accelerate3d"]),
+		MonitorCount:       monitors,
+		VRDE: VRDE{
+			Enabled:  isOn(info["vrde"]),
+			Port:     info["vrdeports"],
+			Address:  info["vrdeaddress"],
+			AuthType: strings.ToLower(info["vrdeauthtype"]),
+		},
+	}
+
+	port, err := strconv.Atoi(info["vrdeport"])
+	if err != nil || port < 0 {
+		port = 0
+	}
+	return d, port
+}
+
+// SetDisplay applies display settings which differ from current ones to powered off VM
+func SetDisplay(vb *vbg.VBox, vm *vbg.VirtualMachine, d Display, current Display) error {
+	args := []string{"modifyvm", vm.UUIDOrName()}
+	if d.VideoMemory != current.VideoMemory {
+		args = append(args, "--vram", strconv.Itoa(d.VideoMemory))
+	}
+	if d.GraphicsController != current.GraphicsController {
+		args = append(args, "--graphicscontroller", d.GraphicsController)
+	}
+	if d.Accelerate3D != current.Accelerate3D {
+		args = append(args, "--accelerate-3d", onOff(d.Accelerate3D))
+	}
+	if d.MonitorCount != current.MonitorCount {
+		args = append(args, "--monitor-count", strconv.Itoa(d.MonitorCount))
+	}
+	if d.VRDE.Enabled != current.VRDE.Enabled {
+		args = append(args, "--vrde", onOff(d.VRDE.Enabled))
+	}
+	if d.VRDE.Enabled {
+		if d.VRDE.Port != current.VRDE.Port {
+			args = append(args, "--vrde-port", d.VRDE.Port)
+		}
+		if d.VRDE.Address != current.VRDE.Address {
+			args = append(args, "--vrde-address", d.VRDE.Address)
+		}
+		if d.VRDE.AuthType != current.VRDE.AuthType {
+			args = append(args, "--vrde-auth-type", d.VRDE.AuthType)
+		}
+	}
+
+	if len(args) == 2 {
+		return nil
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("modifyvm failed: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package pkg
+
+import "testing"
+
+func Test_ReadDisplay(t *testing.T) {
+	info := parseMachineReadable(`vram=128
+graphicscontroller="vmsvga"
+accelerate3d="on"
+monitorcount=2
+vrde="on"
+vrdeport=5001
+vrdeports="5000-5050"
+vrdeaddress="127.0.0.1"
+vrdeauthtype="null"
+`)
+
+	expected := Display{
+		VideoMemory:        128,
+		GraphicsController: "vmsvga",
+		Accelerate3D:       true,
+		MonitorCount:       2,
+		VRDE:               VRDE{Enabled: true, Port: "5000-5050", Address: "127.0.0.1", AuthType: "null"},
+	}
+
+	d, port := ReadDisplay(info)
+	if d != expected {
+		t.Errorf("Expected %+v, got %+v", expected, d)
+	}
+	if port != 5001 {
+		t.Errorf("Expected VRDE port 5001, got %d", port)
+	}
+
+	if _, port := ReadDisplay(parseMachineReadable(`vrde="off"` + "\n" + `vrdeport=-1`)); port != 0 {
+		t.Errorf("Port of stopped VRDE must be 0, got %d", port)
+	}
+}
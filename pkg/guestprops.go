@@ -0,0 +1,176 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	vbg "github.com/mixdone/virtualbox-go"
+	"github.com/sirupsen/logrus"
+)
+
+// guestPropertyPoll is how often guest properties are checked while waiting for guest
+const guestPropertyPoll = 2 * time.Second
+
+// GuestProperty is guest property of VM with its flags, e.g "TRANSIENT, RDONLYGUEST"
+type GuestProperty struct {
+	Name  string
+	Value string
+	Flags string
+}
+
+// Lines of "guestproperty enumerate", VirtualBox 6 prints
+// Name: /VirtualBox/GuestInfo/Net/0/V4/IP, value: 10.0.2.15, timestamp: 1700000000000000000, flags:
+// and VirtualBox 7 prints
+// /VirtualBox/GuestInfo/Net/0/V4/IP = '10.0.2.15' @ 2023-11-14T22:13:20.000000000Z TRANSIENT
+var (
+	reGuestPropertyOld = regexp.MustCompile(`^Name: (.*), value: (.*), timestamp: \d+, flags: ?(.*)$`)
+	reGuestPropertyNew = regexp.MustCompile(`^(\S+)\s+= '(.*)' @ \S+ ?(.*)$`)
+)
+
+// parseGuestProperties parses output of "guestproperty enumerate"
+func parseGuestProperties(out string) map[string]GuestProperty {
+	props := make(map[string]GuestProperty)
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		res := reGuestPropertyOld.FindStringSubmatch(line)
+		if res == nil {
+			res = reGuestPropertyNew.FindStringSubmatch(line)
+		}
+		if res == nil {
+			continue
+		}
+		props[res[1]] = GuestProperty{Name: res[1], Value: res[2], Flags: strings.TrimSpace(res[3])}
+	}
+	return props
+}
+
+// GuestProperties returns guest properties of VM whose names match pattern, e.g "/VirtualBox/GuestInfo/Net/*"
+// empty pattern returns all properties
+func GuestProperties(vb *vbg.VBox, vm *vbg.VirtualMachine, pattern string) (map[string]GuestProperty, error) {
+	args := []string{"guestproperty", "enumerate", vm.UUIDOrName()}
+	if pattern != "" {
+		args = append(args, "--patterns", pattern)
+	}
+
+	out, err := Manage(vb, args...)
+	if err != nil {
+		return nil, fmt.Errorf("guestproperty enumerate failed: %s", err.Error())
+	}
+	return parseGuestProperties(out), nil
+}
+
+// GetGuestProperty returns guest property of VM, ok is false if property is not set
+func GetGuestProperty(vb *vbg.VBox, vm *vbg.VirtualMachine, name string) (GuestProperty, bool, error) {
+	out, err := Manage(vb, "guestproperty", "get", vm.UUIDOrName(), name, "--verbose")
+	if err != nil {
+		return GuestProperty{}, false, fmt.Errorf("guestproperty get failed: %s", err.Error())
+	}
+
+	prop := GuestProperty{Name: name}
+	found := false
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		key, val, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Value":
+			prop.Value, found = strings.TrimPrefix(val, " "), true
+		case "Flags":
+			prop.Flags = strings.TrimSpace(val)
+		}
+	}
+	return prop, found, nil
+}
+
+// WaitForGuestProperty waits until guest sets property to non-empty value, which usually means
+// that guest additions are started, error is returned after timeout or when ctx is done
+func WaitForGuestProperty(ctx context.Context, vb *vbg.VBox, vm *vbg.VirtualMachine, name string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		prop, ok, err := GetGuestProperty(vb, vm, name)
+		if err != nil {
+			return "", err
+		}
+		if ok && prop.Value != "" {
+			return prop.Value, nil
+		}
+
+		logrus.Debugf("Waiting for guest property %s of %s", name, vm.Spec.Name)
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("guest did not set %s within %s", name, timeout)
+		case <-time.After(guestPropertyPoll):
+		}
+	}
+}
+
+// GuestAddresses returns IPv4 and IPv6 addresses reported by guest additions, ordered by interface
+func GuestAddresses(vb *vbg.VBox, vm *vbg.VirtualMachine) ([]string, []string, error) {
+	props, err := GuestProperties(vb, vm, "/VirtualBox/GuestInfo/Net/*")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	count, err := strconv.Atoi(props["/VirtualBox/GuestInfo/Net/Count"].Value)
+	if err != nil {
+		return []string{}, []string{}, nil
+	}
+
+	ipv4, ipv6 := []string{}, []string{}
+	for i := 0; i < count; i++ {
+		if ip := props[fmt.Sprintf("/VirtualBox/GuestInfo/Net/%d/V4/IP", i)].Value; ip != "" {
+			ipv4 = append(ipv4, ip)
+		}
+		if ip := props[fmt.Sprintf("/VirtualBox/GuestInfo/Net/%d/V6/IP", i)].Value; ip != "" {
+			ipv6 = append(ipv6, ip)
+		}
+	}
+	return ipv4, ipv6, nil
+}
+
+// GuestPropertyFlags returns flags which can be set on guest property
+func GuestPropertyFlags() []string {
+	return []string{"TRANSIENT", "TRANSRESET", "RDONLYGUEST", "RDONLYHOST", "READONLY"}
+}
+
+// SetGuestProperty sets guest property of VM, flags are comma separated e.g "TRANSIENT,RDONLYGUEST"
+func SetGuestProperty(vb *vbg.VBox, vm *vbg.VirtualMachine, name, value, flags string) error {
+	args := []string{"guestproperty", "set", vm.UUIDOrName(), name, value}
+	if flags != "" {
+		args = append(args, "--flags", flags)
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("guestproperty set failed: %s", err.Error())
+	}
+	return nil
+}
+
+// DeleteGuestProperty removes guest property of VM
+func DeleteGuestProperty(vb *vbg.VBox, vm *vbg.VirtualMachine, name string) error {
+	if _, err := Manage(vb, "guestproperty", "unset", vm.UUIDOrName(), name); err != nil {
+		return fmt.Errorf("guestproperty unset failed: %s", err.Error())
+	}
+	return nil
+}
+
+// SplitGuestPropertyFlags splits flags like "TRANSIENT, RDONLYGUEST" into list
+func SplitGuestPropertyFlags(flags string) []string {
+	res := []string{}
+	for _, flag := range strings.Split(flags, ",") {
+		if flag = strings.TrimSpace(flag); flag != "" {
+			res = append(res, flag)
+		}
+	}
+	return res
+}
@@ -0,0 +1,28 @@
+package pkg
+
+import "testing"
+
+func Test_parseGuestProperties(t *testing.T) {
+	out := `Name: /VirtualBox/GuestInfo/Net/0/V4/IP, value: 10.0.2.15, timestamp: 1700000000000000000, flags:
+Name: /VirtualBox/HostInfo/GUI/LanguageID, value: C, timestamp: 1700000000000000000, flags: RDONLYGUEST
+/VirtualBox/GuestInfo/Net/1/V4/IP = '192.168.56.10' @ 2023-11-14T22:13:20.000000000Z
+/build/id                         = 'a, b' @ 2023-11-14T22:13:20.000000000Z TRANSIENT, RDONLYGUEST
+`
+
+	expected := map[string]GuestProperty{
+		"/VirtualBox/GuestInfo/Net/0/V4/IP":   {Name: "/VirtualBox/GuestInfo/Net/0/V4/IP", Value: "10.0.2.15"},
+		"/VirtualBox/HostInfo/GUI/LanguageID": {Name: "/VirtualBox/HostInfo/GUI/LanguageID", Value: "C", Flags: "RDONLYGUEST"},
+		"/VirtualBox/GuestInfo/Net/1/V4/IP":   {Name: "/VirtualBox/GuestInfo/Net/1/V4/IP", Value: "192.168.56.10"},
+		"/build/id":                           {Name: "/build/id", Value: "a, b", Flags: "TRANSIENT, RDONLYGUEST"},
+	}
+
+	props := parseGuestProperties(out)
+	if len(props) != len(expected) {
+		t.Fatalf("Expected %d properties, got %d: %v", len(expected), len(props), props)
+	}
+	for name, prop := range expected {
+		if props[name] != prop {
+			t.Errorf("Property %s: expected %+v, got %+v", name, prop, props[name])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// HostInterface is network interface of host which bridged adapters can be attached to
+type HostInterface struct {
+	Name             string
+	MAC              string
+	IPv4Address      string
+	IPv4Netmask      string
+	IPv6Address      string
+	IPv6PrefixLength int
+	Status           string
+	MediumType       string
+	Wireless         bool
+	DHCP             bool
+}
+
+// HostInterfaces returns host interfaces listed by "VBoxManage list bridgedifs"
+func HostInterfaces(vb *vbg.VBox) ([]HostInterface, error) {
+	out, err := Manage(vb, "list", "bridgedifs")
+	if err != nil {
+		return nil, fmt.Errorf("list bridgedifs failed: %s", err.Error())
+	}
+	return parseHostInterfaces(out), nil
+}
+
+// parseHostInterfaces parses output of "VBoxManage list bridgedifs"
+func parseHostInterfaces(out string) []HostInterface {
+	interfaces := []HostInterface{}
+	for _, block := range parseBlocks(out) {
+		prefix, _ := strconv.Atoi(block["IPV6NetworkMaskPrefixLength"])
+		interfaces = append(interfaces, HostInterface{
+			Name:             block["Name"],
+			MAC:              strings.ToLower(block["HardwareAddress"]),
+			IPv4Address:      block["IPAddress"],
+			IPv4Netmask:      block["NetworkMask"],
+			IPv6Address:      block["IPV6Address"],
+			IPv6PrefixLength: prefix,
+			Status:           strings.ToLower(block["Status"]),
+			MediumType:       block["MediumType"],
+			Wireless:         strings.EqualFold(block["Wireless"], "Yes"),
+			DHCP:             strings.EqualFold(block["DHCP"], "Enabled"),
+		})
+	}
+	return interfaces
+}
@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func Test_parseHostInterfaces(t *testing.T) {
+	out := `Name:            eth0
+GUID:            30687465-0000-4000-8000-00155d2a6b10
+DHCP:            Disabled
+IPAddress:       192.168.1.10
+NetworkMask:     255.255.255.0
+IPV6Address:     fe80::215:5dff:fe2a:6b10
+IPV6NetworkMaskPrefixLength: 64
+HardwareAddress: 00:15:5D:2A:6B:10
+MediumType:      Ethernet
+Wireless:        No
+Status:          Up
+VBoxNetworkName: HostInterfaceNetworking-eth0
+
+Name:            wlan0
+GUID:            6e616c77-0030-4000-8000-a4c3f0e1d2c3
+DHCP:            Enabled
+IPAddress:       0.0.0.0
+NetworkMask:     0.0.0.0
+IPV6Address:
+IPV6NetworkMaskPrefixLength: 0
+HardwareAddress: a4:c3:f0:e1:d2:c3
+MediumType:      Ethernet
+Wireless:        Yes
+Status:          Down
+VBoxNetworkName: HostInterfaceNetworking-wlan0
+`
+
+	interfaces := parseHostInterfaces(out)
+	expected := []HostInterface{
+		{
+			Name:             "eth0",
+			MAC:              "00:15:5d:2a:6b:10",
+			IPv4Address:      "192.168.1.10",
+			IPv4Netmask:      "255.255.255.0",
+			IPv6Address:      "fe80::215:5dff:fe2a:6b10",
+			IPv6PrefixLength: 64,
+			Status:           "up",
+			MediumType:       "Ethernet",
+		},
+		{
+			Name:        "wlan0",
+			MAC:         "a4:c3:f0:e1:d2:c3",
+			IPv4Address: "0.0.0.0",
+			IPv4Netmask: "0.0.0.0",
+			Status:      "down",
+			MediumType:  "Ethernet",
+			Wireless:    true,
+			DHCP:        true,
+		},
+	}
+
+	if len(interfaces) != len(expected) {
+		t.Fatalf("Expected %d interfaces, got %d", len(expected), len(interfaces))
+	}
+	for i := range expected {
+		if interfaces[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], interfaces[i])
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// HostOnlyNetwork is host-only network, either host interface created with "hostonlyif"
+// or network created with "hostonlynet" on hosts which have no host-only interfaces, e.g macOS
+type HostOnlyNetwork struct {
+	Type             string
+	Name             string
+	IPv4Address      string
+	IPv4Netmask      string
+	IPv6Address      string
+	IPv6PrefixLength int
+	LowerIP          string
+	UpperIP          string
+}
+
+// HostOnlyTypes returns supported kinds of host-only networks
+func HostOnlyTypes() []string {
+	return []string{"hostonlyif", "hostonlynet"}
+}
+
+// DHCPNetworkName returns name of network DHCP server of host-only interface is bound to
+func (n HostOnlyNetwork) DHCPNetworkName() string {
+	return "HostInterfaceNetworking-" + n.Name
+}
+
+// parseBlocks parses output of "VBoxManage list" which consists of "key: value" blocks separated by empty lines
+func parseBlocks(out string) []map[string]string {
+	var blocks []map[string]string
+	block := map[string]string{}
+
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(block) > 0 {
+				blocks = append(blocks, block)
+				block = map[string]string{}
+			}
+			continue
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		block[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	if len(block) > 0 {
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// HostOnlyNetworks returns host-only networks of given type keyed by name
+func HostOnlyNetworks(vb *vbg.VBox, kind string) (map[string]HostOnlyNetwork, error) {
+	out, err := Manage(vb, "list", kind+"s")
+	if err != nil {
+		return nil, fmt.Errorf("list %ss failed: %s", kind, err.Error())
+	}
+
+	networks := make(map[string]HostOnlyNetwork)
+	for _, block := range parseBlocks(out) {
+		n := HostOnlyNetwork{Type: kind, Name: block["Name"]}
+		if kind == "hostonlyif" {
+			n.IPv4Address = block["IPAddress"]
+			n.IPv4Netmask = block["NetworkMask"]
+			n.IPv6Address = block["IPV6Address"]
+			n.IPv6PrefixLength, _ = strconv.Atoi(block["IPV6NetworkMaskPrefixLength"])
+		} else {
+			n.IPv4Netmask = block["NetworkMask"]
+			n.LowerIP = block["LowerIP"]
+			n.UpperIP = block["UpperIP"]
+		}
+		networks[n.Name] = n
+	}
+	return networks, nil
+}
+
+var reHostOnlyIfCreated = regexp.MustCompile(`Interface '([^']+)' was successfully created`)
+
+// CreateHostOnlyNetwork creates host-only network and configures its addresses
+// name of created host-only interface is chosen by VirtualBox and stored in n,
+// interface is removed again if its addresses can't be configured
+func CreateHostOnlyNetwork(vb *vbg.VBox, n *HostOnlyNetwork) error {
+	if n.Type == "hostonlynet" {
+		if _, err := Manage(vb, "hostonlynet", "add", "--name", n.Name,
+			"--netmask", n.IPv4Netmask,
+			"--lower-ip", n.LowerIP,
+			"--upper-ip", n.UpperIP,
+			"--enable"); err != nil {
+			return fmt.Errorf("hostonlynet add failed: %s", err.Error())
+		}
+		return nil
+	}
+
+	out, err := Manage(vb, "hostonlyif", "create")
+	if err != nil {
+		return fmt.Errorf("hostonlyif create failed: %s", err.Error())
+	}
+	res := reHostOnlyIfCreated.FindStringSubmatch(out)
+	if res == nil {
+		return fmt.Errorf("could not determine name of interface from output: %s", out)
+	}
+	n.Name = res[1]
+
+	if err := ConfigureHostOnlyNetwork(vb, *n); err != nil {
+		if rmErr := RemoveHostOnlyNetwork(vb, *n); rmErr != nil {
+			return fmt.Errorf("%s, removing interface %s failed: %s", err.Error(), n.Name, rmErr.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+// ConfigureHostOnlyNetwork applies addresses of host-only network
+func ConfigureHostOnlyNetwork(vb *vbg.VBox, n HostOnlyNetwork) error {
+	if n.Type == "hostonlynet" {
+		if _, err := Manage(vb, "hostonlynet", "modify", "--name", n.Name,
+			"--netmask", n.IPv4Netmask,
+			"--lower-ip", n.LowerIP,
+			"--upper-ip", n.UpperIP); err != nil {
+			return fmt.Errorf("hostonlynet modify failed: %s", err.Error())
+		}
+		return nil
+	}
+
+	if n.IPv4Address != "" {
+		if _, err := Manage(vb, "hostonlyif", "ipconfig", n.Name, "--ip", n.IPv4Address, "--netmask", n.IPv4Netmask); err != nil {
+			return fmt.Errorf("hostonlyif ipconfig failed: %s", err.Error())
+		}
+	}
+	if n.IPv6Address != "" {
+		if _, err := Manage(vb, "hostonlyif", "ipconfig", n.Name, "--ipv6", n.IPv6Address, "--netmasklengthv6", strconv.Itoa(n.IPv6PrefixLength)); err != nil {
+			return fmt.Errorf("hostonlyif ipconfig failed: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// RemoveHostOnlyNetwork removes host-only network
+func RemoveHostOnlyNetwork(vb *vbg.VBox, n HostOnlyNetwork) error {
+	args := []string{"hostonlyif", "remove", n.Name}
+	if n.Type == "hostonlynet" {
+		args = []string{"hostonlynet", "remove", "--name", n.Name}
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("%s remove failed: %s", n.Type, err.Error())
+	}
+	return nil
+}
+
+// FillNICNames sets names of networks of NICs which virtualbox-go does not read,
+// i.e. bridged adapter, internal network and host-only network
+func FillNICNames(vm *vbg.VirtualMachine, info map[string]string) {
+	for i := range vm.Spec.NICs {
+		index := i + 1
+		var key string
+		switch vm.Spec.NICs[i].Mode {
+		case vbg.NWMode_bridged:
+			key = fmt.Sprintf("bridgeadapter%d", index)
+		case vbg.NWMode_intnet:
+			key = fmt.Sprintf("intnet%d", index)
+		case "hostonlynet":
+			key = fmt.Sprintf("hostonly-network%d", index)
+		default:
+			continue
+		}
+		if name, ok := info[key]; ok {
+			vm.Spec.NICs[i].NetworkName = name
+		}
+	}
+}
+
+// SetHostOnlyNets attaches NICs in "hostonlynet" mode to their networks, which virtualbox-go does not do
+func SetHostOnlyNets(vb *vbg.VBox, vm *vbg.VirtualMachine) error {
+	args := []string{"modifyvm", vm.UUIDOrName()}
+	for i, nic := range vm.Spec.NICs {
+		if nic.Mode == "hostonlynet" {
+			args = append(args, fmt.Sprintf("--host-only-net%d", i+1), nic.NetworkName)
+		}
+	}
+	if len(args) == 2 {
+		return nil
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("modifyvm failed: %s", err.Error())
+	}
+	return nil
+}
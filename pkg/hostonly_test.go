@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"testing"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+func Test_parseBlocks(t *testing.T) {
+	out := `Name:            vboxnet0
+GUID:            786f6276-656e-4074-8000-0a0027000000
+DHCP:            Disabled
+IPAddress:       192.168.56.1
+NetworkMask:     255.255.255.0
+VBoxNetworkName: HostInterfaceNetworking-vboxnet0
+
+Name:            vboxnet1
+IPAddress:       192.168.57.1
+
+`
+
+	blocks := parseBlocks(out)
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+	if blocks[0]["Name"] != "vboxnet0" || blocks[0]["IPAddress"] != "192.168.56.1" || blocks[0]["VBoxNetworkName"] != "HostInterfaceNetworking-vboxnet0" {
+		t.Errorf("Wrong first block %v", blocks[0])
+	}
+	if blocks[1]["Name"] != "vboxnet1" || blocks[1]["IPAddress"] != "192.168.57.1" {
+		t.Errorf("Wrong second block %v", blocks[1])
+	}
+}
+
+func Test_FillNICNames(t *testing.T) {
+	vm := &vbg.VirtualMachine{}
+	vm.Spec.NICs = []vbg.NIC{
+		{Mode: vbg.NWMode_nat},
+		{Mode: vbg.NWMode_bridged},
+		{Mode: vbg.NWMode_intnet},
+		{Mode: "hostonlynet"},
+	}
+
+	FillNICNames(vm, map[string]string{
+		"bridgeadapter2":    "eth0",
+		"intnet3":           "backend",
+		"hostonly-network4": "lab",
+	})
+
+	expected := []string{"", "eth0", "backend", "lab"}
+	for i, nic := range vm.Spec.NICs {
+		if nic.NetworkName != expected[i] {
+			t.Errorf("NIC %d: expected %q, got %q", i+1, expected[i], nic.NetworkName)
+		}
+	}
+}
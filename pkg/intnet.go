@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// IntNetAttachment is network adapter of VM attached to internal network
+type IntNetAttachment struct {
+	VMID   string
+	VMName string
+	Index  int
+}
+
+// IntNetAttachments returns adapters of all registered VMs attached to internal network
+func IntNetAttachments(vb *vbg.VBox, network string) ([]IntNetAttachment, error) {
+	vms, err := ListVMs(vb)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := []IntNetAttachment{}
+	for uuid, name := range vms {
+		info, err := VMInfoMap(vb, uuid)
+		if err != nil {
+			// VM was removed after listing
+			continue
+		}
+		attachments = append(attachments, intNetAttachments(uuid, name, info, network)...)
+	}
+
+	sort.Slice(attachments, func(i, j int) bool {
+		if attachments[i].VMName != attachments[j].VMName {
+			return attachments[i].VMName < attachments[j].VMName
+		}
+		return attachments[i].Index < attachments[j].Index
+	})
+	return attachments, nil
+}
+
+// intNetAttachments returns adapters attached to internal network from "showvminfo --machinereadable" output
+func intNetAttachments(uuid, name string, info map[string]string, network string) []IntNetAttachment {
+	var attachments []IntNetAttachment
+	for key, mode := range info {
+		if !strings.HasPrefix(key, "nic") || mode != "intnet" {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(key, "nic"))
+		if err != nil {
+			continue
+		}
+		if info[fmt.Sprintf("intnet%d", index)] == network {
+			attachments = append(attachments, IntNetAttachment{VMID: uuid, VMName: name, Index: index})
+		}
+	}
+	return attachments
+}
@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"sort"
+	"testing"
+)
+
+func Test_intNetAttachments(t *testing.T) {
+	info := map[string]string{
+		"nic1":       "nat",
+		"nic2":       "intnet",
+		"intnet2":    "backend",
+		"nic3":       "intnet",
+		"intnet3":    "frontend",
+		"nic4":       "intnet",
+		"intnet4":    "backend",
+		"nictype2":   "82540EM",
+		"nicspeed2":  "0",
+		"nic5":       "none",
+		"hostonlyif": "intnet",
+	}
+
+	attachments := intNetAttachments("uuid", "web", info, "backend")
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Index < attachments[j].Index })
+
+	if len(attachments) != 2 {
+		t.Fatalf("Expected 2 attachments, got %v", attachments)
+	}
+	for i, index := range []int{2, 4} {
+		a := attachments[i]
+		if a.Index != index || a.VMID != "uuid" || a.VMName != "web" {
+			t.Errorf("Wrong attachment %v, expected index %d", a, index)
+		}
+	}
+
+	if attachments := intNetAttachments("uuid", "web", info, "db"); len(attachments) != 0 {
+		t.Errorf("Expected no attachments, got %v", attachments)
+	}
+}
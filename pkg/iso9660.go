@@ -0,0 +1,235 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ISO 9660 image with Joliet extension holding files in root directory,
+// which is enough for seed images of cloud-init
+
+const (
+	isoSector = 2048
+
+	// Sectors of image: system area, volume descriptors, path tables, root directories, then file data
+	isoPrimarySector     = 16
+	isoJolietSector      = 17
+	isoTerminatorSector  = 18
+	isoPathLSector       = 19
+	isoPathMSector       = 20
+	isoJolietPathLSector = 21
+	isoJolietPathMSector = 22
+	isoRootSector        = 23
+	isoJolietRootSector  = 24
+	isoDataSector        = 25
+)
+
+// isoFile is file of image with its placement
+type isoFile struct {
+	name   string
+	data   []byte
+	sector uint32
+}
+
+// WriteISO writes ISO 9660 image with volume label and files in root directory to path
+// file names are kept as is in Joliet directory and converted to 8.3 form in primary one
+func WriteISO(path string, label string, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sector := uint32(isoDataSector)
+	entries := make([]isoFile, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, isoFile{name: name, data: files[name], sector: sector})
+		sector += uint32((len(files[name]) + isoSector - 1) / isoSector)
+	}
+	total := sector
+
+	image := make([]byte, int(total)*isoSector)
+	at := func(sector uint32) []byte {
+		return image[int(sector)*isoSector:]
+	}
+
+	now := time.Now().UTC()
+
+	primaryRoot := isoDirectory(entries, isoRootSector, now, isoPrimaryName)
+	jolietRoot := isoDirectory(entries, isoJolietRootSector, now, isoJolietName)
+	copy(at(isoRootSector), primaryRoot)
+	copy(at(isoJolietRootSector), jolietRoot)
+
+	copy(at(isoPrimarySector), isoVolumeDescriptor(1, label, total, isoPathLSector, isoPathMSector, isoRootSector, now))
+	copy(at(isoJolietSector), isoVolumeDescriptor(2, label, total, isoJolietPathLSector, isoJolietPathMSector, isoJolietRootSector, now))
+
+	terminator := at(isoTerminatorSector)
+	terminator[0] = 255
+	copy(terminator[1:], "CD001")
+	terminator[6] = 1
+
+	copy(at(isoPathLSector), isoPathTable(isoRootSector, binary.LittleEndian))
+	copy(at(isoPathMSector), isoPathTable(isoRootSector, binary.BigEndian))
+	copy(at(isoJolietPathLSector), isoPathTable(isoJolietRootSector, binary.LittleEndian))
+	copy(at(isoJolietPathMSector), isoPathTable(isoJolietRootSector, binary.BigEndian))
+
+	for _, file := range entries {
+		copy(at(file.sector), file.data)
+	}
+
+	return os.WriteFile(path, image, 0640)
+}
+
+// isoPrimaryName converts name to upper case 8.3 form with version, e.g "user-data" to "USER_DAT.;1"
+func isoPrimaryName(name string) []byte {
+	clean := func(s string, max int) string {
+		s = strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+				return r
+			case r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			}
+			return '_'
+		}, s)
+		if len(s) > max {
+			s = s[:max]
+		}
+		return s
+	}
+
+	base, ext, _ := strings.Cut(name, ".")
+	return []byte(clean(base, 8) + "." + clean(ext, 3) + ";1")
+}
+
+// isoJolietName encodes name in UCS-2 big-endian
+func isoJolietName(name string) []byte {
+	return isoUCS2(name)
+}
+
+// isoUCS2 encodes s in UCS-2 big-endian
+func isoUCS2(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	buf := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.BigEndian.PutUint16(buf[i*2:], u)
+	}
+	return buf
+}
+
+// isoBothEndian32 writes v in little-endian and then in big-endian form
+func isoBothEndian32(buf []byte, v uint32) {
+	binary.LittleEndian.PutUint32(buf, v)
+	binary.BigEndian.PutUint32(buf[4:], v)
+}
+
+// isoBothEndian16 writes v in little-endian and then in big-endian form
+func isoBothEndian16(buf []byte, v uint16) {
+	binary.LittleEndian.PutUint16(buf, v)
+	binary.BigEndian.PutUint16(buf[2:], v)
+}
+
+// isoRecord returns directory record of extent with given identifier
+func isoRecord(id []byte, sector uint32, size uint32, dir bool, t time.Time) []byte {
+	length := 33 + len(id)
+	if length%2 != 0 {
+		length++
+	}
+
+	rec := make([]byte, length)
+	rec[0] = byte(length)
+	isoBothEndian32(rec[2:], sector)
+	isoBothEndian32(rec[10:], size)
+	rec[18] = byte(t.Year() - 1900)
+	rec[19] = byte(t.Month())
+	rec[20] = byte(t.Day())
+	rec[21] = byte(t.Hour())
+	rec[22] = byte(t.Minute())
+	rec[23] = byte(t.Second())
+	if dir {
+		rec[25] = 2
+	}
+	isoBothEndian16(rec[28:], 1)
+	rec[32] = byte(len(id))
+	copy(rec[33:], id)
+	return rec
+}
+
+// isoDirectory returns root directory with "." and ".." entries followed by files
+func isoDirectory(files []isoFile, sector uint32, t time.Time, name func(string) []byte) []byte {
+	dir := append(isoRecord([]byte{0}, sector, isoSector, true, t), isoRecord([]byte{1}, sector, isoSector, true, t)...)
+	for _, file := range files {
+		dir = append(dir, isoRecord(name(file.name), file.sector, uint32(len(file.data)), false, t)...)
+	}
+	return dir
+}
+
+// isoPathTable returns path table containing only root directory
+func isoPathTable(rootSector uint32, order binary.ByteOrder) []byte {
+	table := make([]byte, 10)
+	table[0] = 1
+	order.PutUint32(table[2:], rootSector)
+	order.PutUint16(table[6:], 1)
+	return table
+}
+
+// isoDate returns date and time of volume descriptor
+func isoDate(t time.Time) []byte {
+	return append([]byte(t.Format("20060102150405")+"00"), 0)
+}
+
+// isoVolumeDescriptor returns primary (type 1) or Joliet supplementary (type 2) volume descriptor
+func isoVolumeDescriptor(kind byte, label string, sectors uint32, pathL, pathM, root uint32, t time.Time) []byte {
+	vd := make([]byte, isoSector)
+	vd[0] = kind
+	copy(vd[1:], "CD001")
+	vd[6] = 1
+
+	// Text fields are padded with spaces, in UCS-2 for Joliet
+	text := func(field []byte, s string) {
+		if kind == 1 {
+			for i := range field {
+				field[i] = ' '
+			}
+			copy(field, strings.ToUpper(s))
+			return
+		}
+		for i := 0; i+1 < len(field); i += 2 {
+			field[i], field[i+1] = 0, ' '
+		}
+		copy(field, isoUCS2(s))
+	}
+
+	text(vd[8:40], "")
+	text(vd[40:72], label)
+	isoBothEndian32(vd[80:], sectors)
+	if kind == 2 {
+		// UCS-2 level 3
+		copy(vd[88:], "%/E")
+	}
+	isoBothEndian16(vd[120:], 1)
+	isoBothEndian16(vd[124:], 1)
+	isoBothEndian16(vd[128:], isoSector)
+	isoBothEndian32(vd[132:], 10)
+	binary.LittleEndian.PutUint32(vd[140:], pathL)
+	binary.BigEndian.PutUint32(vd[148:], pathM)
+	copy(vd[156:190], isoRecord([]byte{0}, root, isoSector, true, t))
+	text(vd[190:318], "")
+	text(vd[318:446], "")
+	text(vd[446:574], "")
+	text(vd[574:702], "")
+	text(vd[702:739], "")
+	text(vd[739:776], "")
+	text(vd[776:813], "")
+
+	copy(vd[813:], isoDate(t))
+	copy(vd[830:], isoDate(t))
+	copy(vd[847:], append([]byte(strings.Repeat("0", 16)), 0))
+	copy(vd[864:], append([]byte(strings.Repeat("0", 16)), 0))
+	vd[881] = 1
+	return vd
+}
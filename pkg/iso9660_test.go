@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readISODir returns files of root directory described by volume descriptor at sector
+func readISODir(t *testing.T, image []byte, sector int, joliet bool) map[string][]byte {
+	vd := image[sector*isoSector:]
+	if string(vd[1:6]) != "CD001" {
+		t.Fatalf("Wrong volume descriptor at sector %d", sector)
+	}
+
+	root := vd[156:]
+	extent := binary.LittleEndian.Uint32(root[2:])
+	length := binary.LittleEndian.Uint32(root[10:])
+	dir := image[int(extent)*isoSector : int(extent)*isoSector+int(length)]
+
+	files := map[string][]byte{}
+	for off := 0; off < len(dir) && dir[off] != 0; off += int(dir[off]) {
+		rec := dir[off:]
+		id := rec[33 : 33+int(rec[32])]
+		if rec[25]&2 != 0 {
+			continue
+		}
+
+		name := string(id)
+		if joliet {
+			runes := make([]rune, 0, len(id)/2)
+			for i := 0; i+1 < len(id); i += 2 {
+				runes = append(runes, rune(binary.BigEndian.Uint16(id[i:])))
+			}
+			name = string(runes)
+		}
+
+		start := int(binary.LittleEndian.Uint32(rec[2:])) * isoSector
+		files[name] = image[start : start+int(binary.LittleEndian.Uint32(rec[10:]))]
+	}
+	return files
+}
+
+func Test_WriteISO(t *testing.T) {
+	files := map[string][]byte{
+		"user-data":      []byte("#cloud-config\nhostname: test\n"),
+		"meta-data":      []byte("instance-id: test\n"),
+		"network-config": bytes.Repeat([]byte("x"), 3*isoSector+1),
+	}
+
+	path := filepath.Join(t.TempDir(), "cidata.iso")
+	if err := WriteISO(path, "cidata", files); err != nil {
+		t.Fatal(err)
+	}
+
+	image, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(image)%isoSector != 0 {
+		t.Errorf("Image size %d is not multiple of sector", len(image))
+	}
+
+	primary := image[isoPrimarySector*isoSector:]
+	if primary[0] != 1 || string(bytes.TrimRight(primary[40:72], " ")) != "CIDATA" {
+		t.Errorf("Wrong primary volume descriptor")
+	}
+	if sectors := binary.LittleEndian.Uint32(primary[80:]); int(sectors)*isoSector != len(image) {
+		t.Errorf("Volume size %d does not match image size %d", sectors, len(image))
+	}
+
+	joliet := image[isoJolietSector*isoSector:]
+	if joliet[0] != 2 || string(joliet[88:91]) != "%/E" {
+		t.Errorf("Wrong Joliet volume descriptor")
+	}
+	if label := string(bytes.ReplaceAll(joliet[40:52], []byte{0}, nil)); label != "cidata" {
+		t.Errorf("Wrong Joliet label %q", label)
+	}
+	if image[isoTerminatorSector*isoSector] != 255 {
+		t.Errorf("Volume descriptor set is not terminated")
+	}
+
+	got := readISODir(t, image, isoJolietSector, true)
+	for name, data := range files {
+		if !bytes.Equal(got[name], data) {
+			t.Errorf("Joliet file %s differs", name)
+		}
+	}
+
+	primaryFiles := readISODir(t, image, isoPrimarySector, false)
+	if !bytes.Equal(primaryFiles["USER_DAT.;1"], files["user-data"]) {
+		t.Errorf("Primary file USER_DAT.;1 differs, files: %v", len(primaryFiles))
+	}
+}
+
+func Test_CloudInitSeedFiles(t *testing.T) {
+	files := CloudInitSeed{UserData: "#cloud-config\n"}.Files("uuid", "vm")
+	if string(files["meta-data"]) != "instance-id: uuid\nlocal-hostname: vm\n" {
+		t.Errorf("Wrong default meta-data %q", files["meta-data"])
+	}
+	if _, ok := files["network-config"]; ok {
+		t.Errorf("Empty network-config must not be written")
+	}
+
+	files = CloudInitSeed{MetaData: "instance-id: custom\n", NetworkConfig: "version: 2\n"}.Files("uuid", "vm")
+	if string(files["meta-data"]) != "instance-id: custom\n" || string(files["network-config"]) != "version: 2\n" {
+		t.Errorf("Configured files are not kept")
+	}
+	if _, ok := files["user-data"]; !ok {
+		t.Errorf("user-data must always be written")
+	}
+}
@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// NATSettings is settings of NAT engine of network adapter in "nat" mode
+// empty Network means default 10.0.N.0/24 network, empty AliasMode means default mode
+type NATSettings struct {
+	Network         string
+	DNSProxy        bool
+	DNSHostResolver bool
+	AliasMode       []string
+	TFTPPrefix      string
+	TFTPFile        string
+	BindIP          string
+}
+
+// IsDefault checks whether NAT engine keeps default settings
+func (s NATSettings) IsDefault() bool {
+	return s.Network == "" && !s.DNSProxy && !s.DNSHostResolver && len(s.AliasMode) == 0 &&
+		s.TFTPPrefix == "" && s.TFTPFile == "" && s.BindIP == ""
+}
+
+// anyBindIP is bind address of NAT engine which is not bound to any host address,
+// VBoxManage does not accept empty address to reset bind address
+const anyBindIP = "0.0.0.0"
+
+// AliasModes returns supported flags of NAT alias mode
+func AliasModes() []string {
+	return []string{"log", "proxyonly", "sameports"}
+}
+
+// Adapter of VM settings file, NAT engine of adapters in other modes is kept in DisabledModes and ignored
+type vboxAdapter struct {
+	Slot int `xml:"slot,attr"`
+	NAT  *struct {
+		Network string `xml:"network,attr"`
+		HostIP  string `xml:"hostip,attr"`
+		DNS     struct {
+			UseProxy        bool `xml:"use-proxy,attr"`
+			UseHostResolver bool `xml:"use-host-resolver,attr"`
+		} `xml:"DNS"`
+		Alias struct {
+			Logging      bool `xml:"logging,attr"`
+			ProxyOnly    bool `xml:"proxy-only,attr"`
+			UseSamePorts bool `xml:"use-same-ports,attr"`
+		} `xml:"Alias"`
+		TFTP struct {
+			Prefix   string `xml:"prefix,attr"`
+			BootFile string `xml:"boot-file,attr"`
+		} `xml:"TFTP"`
+	} `xml:"NAT"`
+}
+
+// parseNATSettings parses NAT settings of adapters from VM settings file keyed by index of adapter
+// "showvminfo" does not print DNS, alias and TFTP settings of NAT engine
+func parseNATSettings(r io.Reader) (map[int]NATSettings, error) {
+	settings := make(map[int]NATSettings)
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return settings, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "Adapter" {
+			continue
+		}
+
+		var adapter vboxAdapter
+		if err := decoder.DecodeElement(&adapter, &start); err != nil {
+			return nil, err
+		}
+		if adapter.NAT == nil {
+			continue
+		}
+
+		nat := NATSettings{
+			Network:         adapter.NAT.Network,
+			DNSProxy:        adapter.NAT.DNS.UseProxy,
+			DNSHostResolver: adapter.NAT.DNS.UseHostResolver,
+			AliasMode:       []string{},
+			TFTPPrefix:      adapter.NAT.TFTP.Prefix,
+			TFTPFile:        adapter.NAT.TFTP.BootFile,
+			BindIP:          adapter.NAT.HostIP,
+		}
+		if nat.BindIP == anyBindIP {
+			nat.BindIP = ""
+		}
+		if adapter.NAT.Alias.Logging {
+			nat.AliasMode = append(nat.AliasMode, "log")
+		}
+		if adapter.NAT.Alias.ProxyOnly {
+			nat.AliasMode = append(nat.AliasMode, "proxyonly")
+		}
+		if adapter.NAT.Alias.UseSamePorts {
+			nat.AliasMode = append(nat.AliasMode, "sameports")
+		}
+		settings[adapter.Slot+1] = nat
+	}
+}
+
+// readNATSettings returns NAT settings of adapters from VM settings file
+func readNATSettings(cfgFile string) (map[int]NATSettings, error) {
+	f, err := os.Open(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	settings, err := parseNATSettings(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s failed: %s", cfgFile, err.Error())
+	}
+	return settings, nil
+}
+
+// natArgs returns options of "modifyvm" for NAT settings of adapter which differ from current ones
+func natArgs(index int, s NATSettings, current NATSettings) []string {
+	var args []string
+	if s.Network != current.Network {
+		network := s.Network
+		if network == "" {
+			network = "default"
+		}
+		args = append(args, fmt.Sprintf("--natnet%d", index), network)
+	}
+	if s.DNSProxy != current.DNSProxy {
+		args = append(args, fmt.Sprintf("--natdnsproxy%d", index), onOff(s.DNSProxy))
+	}
+	if s.DNSHostResolver != current.DNSHostResolver {
+		args = append(args, fmt.Sprintf("--natdnshostresolver%d", index), onOff(s.DNSHostResolver))
+	}
+	if aliasMode, currentMode := joinAliasMode(s.AliasMode), joinAliasMode(current.AliasMode); aliasMode != currentMode {
+		args = append(args, fmt.Sprintf("--nataliasmode%d", index), aliasMode)
+	}
+	if s.TFTPPrefix != current.TFTPPrefix {
+		args = append(args, fmt.Sprintf("--nattftpprefix%d", index), s.TFTPPrefix)
+	}
+	if s.TFTPFile != current.TFTPFile {
+		args = append(args, fmt.Sprintf("--nattftpfile%d", index), s.TFTPFile)
+	}
+	if s.BindIP != current.BindIP {
+		bindIP := s.BindIP
+		if bindIP == "" {
+			bindIP = anyBindIP
+		}
+		args = append(args, fmt.Sprintf("--natbindip%d", index), bindIP)
+	}
+	return args
+}
+
+// joinAliasMode returns value of "--nataliasmode" option for alias mode flags
+func joinAliasMode(flags []string) string {
+	if len(flags) == 0 {
+		return "default"
+	}
+
+	sorted := append([]string{}, flags...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseNATSettings(t *testing.T) {
+	cfg := `<?xml version="1.0"?>
+<VirtualBox xmlns="http://www.virtualbox.org/" version="1.19-linux">
+  <Machine uuid="{0c2a1f44-5d8e-4b8a-9d6a-3f1e2c4b5a69}" name="web">
+    <Hardware>
+      <Network>
+        <Adapter slot="0" enabled="true" MACAddress="080027A1B2C3" type="82540EM">
+          <NAT localhost-reachable="true" network="192.168.100.0/24" hostip="127.0.0.1">
+            <DNS use-proxy="true" use-host-resolver="true"/>
+            <Alias logging="true" use-same-ports="true"/>
+            <TFTP prefix="/srv/tftp" boot-file="pxelinux.0"/>
+            <Forwarding name="ssh" proto="1" hostport="2222" guestport="22"/>
+          </NAT>
+        </Adapter>
+        <Adapter slot="1" enabled="true" MACAddress="0800276D4E5F" type="virtio">
+          <DisabledModes>
+            <NAT network="10.9.0.0/24"/>
+          </DisabledModes>
+          <BridgedInterface name="eth0"/>
+        </Adapter>
+        <Adapter slot="2" enabled="true" MACAddress="080027112233" type="82540EM">
+          <NAT localhost-reachable="true" hostip="0.0.0.0"/>
+        </Adapter>
+      </Network>
+    </Hardware>
+  </Machine>
+</VirtualBox>
+`
+
+	settings, err := parseNATSettings(strings.NewReader(cfg))
+	if err != nil {
+		t.Fatalf("parseNATSettings failed: %s", err)
+	}
+
+	expected := map[int]NATSettings{
+		1: {
+			Network:         "192.168.100.0/24",
+			DNSProxy:        true,
+			DNSHostResolver: true,
+			AliasMode:       []string{"log", "sameports"},
+			TFTPPrefix:      "/srv/tftp",
+			TFTPFile:        "pxelinux.0",
+			BindIP:          "127.0.0.1",
+		},
+		3: {AliasMode: []string{}},
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, settings)
+	}
+}
+
+func Test_natArgs(t *testing.T) {
+	current := NATSettings{AliasMode: []string{}}
+
+	if args := natArgs(1, NATSettings{}, current); len(args) != 0 {
+		t.Errorf("Expected no options for default settings, got %v", args)
+	}
+
+	args := natArgs(2, NATSettings{
+		Network:   "192.168.100.0/24",
+		DNSProxy:  true,
+		AliasMode: []string{"sameports", "log"},
+		BindIP:    "127.0.0.1",
+	}, current)
+	expected := []string{
+		"--natnet2", "192.168.100.0/24",
+		"--natdnsproxy2", "on",
+		"--nataliasmode2", "log,sameports",
+		"--natbindip2", "127.0.0.1",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected %v, got %v", expected, args)
+	}
+
+	args = natArgs(1, NATSettings{}, NATSettings{Network: "192.168.100.0/24", AliasMode: []string{"log"}, BindIP: "127.0.0.1"})
+	expected = []string{"--natnet1", "default", "--nataliasmode1", "default", "--natbindip1", "0.0.0.0"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected %v, got %v", expected, args)
+	}
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// NICSettings is settings of network adapter which virtualbox-go does not manage
+// MAC is 12 hex digits as VBoxManage prints it, empty BandwidthGroup and TraceFile mean none
+// NAT is only used by adapters in "nat" mode
+type NICSettings struct {
+	MAC             string
+	PromiscuousMode string
+	BootPriority    int
+	BandwidthGroup  string
+	TraceFile       string
+	NAT             NATSettings
+}
+
+// PromiscuousModes returns supported promiscuous mode policies of network adapter
+func PromiscuousModes() []string {
+	return []string{"deny", "allow-vms", "allow-all"}
+}
+
+// Lines of network adapters in "showvminfo", machine readable output has no trace, boot priority, promiscuous mode and bandwidth group, e.g
+// NIC 1:  MAC: 080027A1B2C3, Attachment: NAT, Cable connected: on, Trace: off (file: none), Type: 82540EM, Reported speed: 0 Mbps, Boot priority: 0, Promisc Policy: deny, Bandwidth group: none
+var reNIC = regexp.MustCompile(`^NIC (\d+):\s+MAC: ([0-9A-Fa-f]{12}), .*, Trace: (on|off) \(file: (.*)\), Type: .*, Boot priority: (\d+), Promisc Policy: ([a-z-]+), Bandwidth group: (.*)$`)
+
+// parseNICSettings parses settings of network adapters from "showvminfo" output keyed by index of adapter
+func parseNICSettings(out string) map[int]NICSettings {
+	settings := make(map[int]NICSettings)
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		res := reNIC.FindStringSubmatch(strings.TrimSpace(s.Text()))
+		if res == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(res[1])
+		priority, _ := strconv.Atoi(res[5])
+
+		nic := NICSettings{
+			MAC:             strings.ToUpper(res[2]),
+			PromiscuousMode: res[6],
+			BootPriority:    priority,
+		}
+		if res[3] == "on" && res[4] != "none" {
+			nic.TraceFile = res[4]
+		}
+		if res[7] != "none" {
+			nic.BandwidthGroup = res[7]
+		}
+		settings[index] = nic
+	}
+	return settings
+}
+
+// NICSettingsOf returns settings of network adapters of VM keyed by index of adapter
+func NICSettingsOf(vb *vbg.VBox, vm *vbg.VirtualMachine) (map[int]NICSettings, error) {
+	out, err := Manage(vb, "showvminfo", vm.UUIDOrName())
+	if err != nil {
+		return nil, fmt.Errorf("showvminfo failed: %s", err.Error())
+	}
+	settings := parseNICSettings(out)
+
+	info, err := VMInfoMap(vb, vm.UUIDOrName())
+	if err != nil {
+		return nil, err
+	}
+	nat, err := readNATSettings(info["CfgFile"])
+	if err != nil {
+		return nil, err
+	}
+	for index, s := range settings {
+		s.NAT = nat[index]
+		settings[index] = s
+	}
+	return settings, nil
+}
+
+// SetNICSettings applies settings of network adapter which differ from current ones to powered off VM
+// empty MAC keeps address assigned by VirtualBox
+func SetNICSettings(vb *vbg.VBox, vm *vbg.VirtualMachine, index int, s NICSettings, current NICSettings) error {
+	args := []string{"modifyvm", vm.UUIDOrName()}
+	if mac := NormalizeMAC(s.MAC); mac != "" && mac != current.MAC {
+		args = append(args, fmt.Sprintf("--macaddress%d", index), mac)
+	}
+	if s.PromiscuousMode != current.PromiscuousMode {
+		args = append(args, fmt.Sprintf("--nicpromisc%d", index), s.PromiscuousMode)
+	}
+	if s.BootPriority != current.BootPriority {
+		args = append(args, fmt.Sprintf("--nicbootprio%d", index), strconv.Itoa(s.BootPriority))
+	}
+	if s.BandwidthGroup != current.BandwidthGroup {
+		group := s.BandwidthGroup
+		if group == "" {
+			group = "none"
+		}
+		args = append(args, fmt.Sprintf("--nicbandwidthgroup%d", index), group)
+	}
+	if s.TraceFile != current.TraceFile {
+		if s.TraceFile == "" {
+			args = append(args, fmt.Sprintf("--nictrace%d", index), "off")
+		} else {
+			args = append(args, fmt.Sprintf("--nictrace%d", index), "on", fmt.Sprintf("--nictracefile%d", index), s.TraceFile)
+		}
+	}
+	args = append(args, natArgs(index, s.NAT, current.NAT)...)
+
+	if len(args) == 2 {
+		return nil
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("modifyvm failed: %s", err.Error())
+	}
+	return nil
+}
+
+// NormalizeMAC returns MAC address as 12 upper case hex digits which VBoxManage expects
+func NormalizeMAC(mac string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(mac))
+}
+
+// FormatMAC returns MAC address in form of "08:00:27:a1:b2:c3"
+func FormatMAC(mac string) string {
+	mac = strings.ToLower(NormalizeMAC(mac))
+	if len(mac) != 12 {
+		return mac
+	}
+
+	parts := make([]string, 0, 6)
+	for i := 0; i < len(mac); i += 2 {
+		parts = append(parts, mac[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,52 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseNICSettings(t *testing.T) {
+	out := `Name:                        web
+NIC 1:                       MAC: 080027A1B2C3, Attachment: NAT, Cable connected: on, Trace: off (file: none), Type: 82540EM, Reported speed: 0 Mbps, Boot priority: 0, Promisc Policy: deny, Bandwidth group: none
+NIC 1 Settings:  MTU: 0, Socket (send: 64, receive: 64), TCP Window (send:64, receive: 64)
+NIC 2:                       MAC: 0800276d4e5f, Attachment: Bridged Interface 'eth0', Cable connected: off, Trace: on (file: /tmp/nic2.pcap), Type: virtio, Reported speed: 0 Mbps, Boot priority: 2, Promisc Policy: allow-all, Bandwidth group: slow
+NIC 3:                       disabled
+`
+
+	settings := parseNICSettings(out)
+	expected := map[int]NICSettings{
+		1: {MAC: "080027A1B2C3", PromiscuousMode: "deny"},
+		2: {MAC: "0800276D4E5F", PromiscuousMode: "allow-all", BootPriority: 2, BandwidthGroup: "slow", TraceFile: "/tmp/nic2.pcap"},
+	}
+
+	if len(settings) != len(expected) {
+		t.Fatalf("Expected %d adapters, got %v", len(expected), settings)
+	}
+	for index, nic := range expected {
+		if !reflect.DeepEqual(settings[index], nic) {
+			t.Errorf("NIC %d: expected %+v, got %+v", index, nic, settings[index])
+		}
+	}
+}
+
+func Test_MAC(t *testing.T) {
+	tests := []struct {
+		mac        string
+		normalized string
+		formatted  string
+	}{
+		{"08:00:27:A1:B2:C3", "080027A1B2C3", "08:00:27:a1:b2:c3"},
+		{"08-00-27-a1-b2-c3", "080027A1B2C3", "08:00:27:a1:b2:c3"},
+		{"080027a1b2c3", "080027A1B2C3", "08:00:27:a1:b2:c3"},
+		{"", "", ""},
+	}
+
+	for _, test := range tests {
+		if got := NormalizeMAC(test.mac); got != test.normalized {
+			t.Errorf("NormalizeMAC(%q): expected %q, got %q", test.mac, test.normalized, got)
+		}
+		if got := FormatMAC(test.mac); got != test.formatted {
+			t.Errorf("FormatMAC(%q): expected %q, got %q", test.mac, test.formatted, got)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// Platform is firmware, boot and chipset settings of VM
+type Platform struct {
+	Firmware   string
+	BootOrder  []string
+	Chipset    string
+	SecureBoot bool
+	TPMType    string
+	RTCUseUTC  bool
+	IOAPIC     bool
+}
+
+// Firmwares returns supported firmware types
+func Firmwares() []string {
+	return []string{"bios", "efi", "efi64"}
+}
+
+// BootDevices returns devices which can be used in boot order
+func BootDevices() []string {
+	return []string{"none", "floppy", "dvd", "disk", "net"}
+}
+
+// Chipsets returns supported chipsets
+func Chipsets() []string {
+	return []string{"piix3", "ich9"}
+}
+
+// TPMTypes returns supported TPM types
+func TPMTypes() []string {
+	return []string{"none", "1.2", "2.0"}
+}
+
+// maxBootDevices is number of boot order slots of VM
+const maxBootDevices = 4
+
+// SetPlatform applies platform settings to powered off VM
+// secure boot is switched only when it differs from current, as enabling it resets UEFI variables
+func SetPlatform(vb *vbg.VBox, vm *vbg.VirtualMachine, p Platform) error {
+	tpm := p.TPMType
+	if tpm != "none" {
+		tpm = "v" + tpm
+	}
+
+	args := []string{"modifyvm", vm.UUIDOrName(),
+		"--firmware", p.Firmware,
+		"--chipset", p.Chipset,
+		"--tpm-type", tpm,
+		"--rtc-use-utc", onOff(p.RTCUseUTC),
+		"--ioapic", onOff(p.IOAPIC),
+	}
+	if len(p.BootOrder) > 0 {
+		for i := 0; i < maxBootDevices; i++ {
+			device := "none"
+			if i < len(p.BootOrder) {
+				device = p.BootOrder[i]
+			}
+			args = append(args, fmt.Sprintf("--boot%d", i+1), device)
+		}
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("modifyvm failed: %s", err.Error())
+	}
+
+	info, err := VMInfoMap(vb, vm.UUIDOrName())
+	if err != nil {
+		return err
+	}
+	if ReadPlatform(info).SecureBoot == p.SecureBoot {
+		return nil
+	}
+
+	if p.SecureBoot {
+		// Secure boot needs UEFI variable store with Microsoft and Oracle keys
+		for _, cmd := range []string{"inituefivarstore", "enrollmssignatures", "enrollorclpk"} {
+			if _, err := Manage(vb, "modifynvram", vm.UUIDOrName(), cmd); err != nil {
+				return fmt.Errorf("modifynvram %s failed: %s", cmd, err.Error())
+			}
+		}
+	}
+
+	flag := "--disable"
+	if p.SecureBoot {
+		flag = "--enable"
+	}
+	if _, err := Manage(vb, "modifynvram", vm.UUIDOrName(), "secureboot", flag); err != nil {
+		return fmt.Errorf("modifynvram secureboot failed: %s", err.Error())
+	}
+	return nil
+}
+
+// ReadPlatform returns platform settings from "showvminfo --machinereadable" output
+func ReadPlatform(info map[string]string) Platform {
+	p := Platform{
+		Firmware:   strings.ToLower(info["firmware"]),
+		Chipset:    strings.ToLower(info["chipset"]),
+		SecureBoot: isOn(info["SecureBoot"]),
+		TPMType:    "none",
+		RTCUseUTC:  isOn(info["rtcuseutc"]),
+		IOAPIC:     isOn(info["ioapic"]),
+		BootOrder:  []string{},
+	}
+
+	// e.g "v2_0" or "v2.0"
+	if tpm := strings.TrimPrefix(strings.ReplaceAll(info["tpm_type"], "_", "."), "v"); tpm != "" {
+		p.TPMType = tpm
+	}
+
+	// Trailing empty slots are not part of boot order
+	for i := 1; i <= maxBootDevices; i++ {
+		p.BootOrder = append(p.BootOrder, info[fmt.Sprintf("boot%d", i)])
+	}
+	for len(p.BootOrder) > 0 && (p.BootOrder[len(p.BootOrder)-1] == "none" || p.BootOrder[len(p.BootOrder)-1] == "") {
+		p.BootOrder = p.BootOrder[:len(p.BootOrder)-1]
+	}
+
+	return p
+}
+
+// onOff converts flag to value of VBoxManage option
+func onOff(flag bool) string {
+	if flag {
+		return "on"
+	}
+	return "off"
+}
+
+// isOn checks whether value of "showvminfo" option means enabled
+func isOn(value string) bool {
+	switch strings.ToLower(value) {
+	case "on", "enabled", "true", "1":
+		return true
+	}
+	return false
+}
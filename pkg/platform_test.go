@@ -0,0 +1,38 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ReadPlatform(t *testing.T) {
+	info := parseMachineReadable(`firmware="EFI64"
+chipset="ich9"
+SecureBoot="on"
+tpm_type="v2.0"
+rtcuseutc="on"
+ioapic="off"
+boot1="disk"
+boot2="dvd"
+boot3="none"
+boot4="none"
+`)
+
+	expected := Platform{
+		Firmware:   "efi64",
+		BootOrder:  []string{"disk", "dvd"},
+		Chipset:    "ich9",
+		SecureBoot: true,
+		TPMType:    "2.0",
+		RTCUseUTC:  true,
+		IOAPIC:     false,
+	}
+
+	if p := ReadPlatform(info); !reflect.DeepEqual(p, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, p)
+	}
+
+	if p := ReadPlatform(parseMachineReadable(`firmware="BIOS"` + "\n" + `tpm_type="none"`)); p.TPMType != "none" || p.Firmware != "bios" {
+		t.Errorf("Wrong platform of VM without TPM: %+v", p)
+	}
+}
@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// SharedFolder is host folder shared with guest
+// transient folder exists only while VM is running
+type SharedFolder struct {
+	Name       string
+	HostPath   string
+	MountPoint string
+	ReadOnly   bool
+	AutoMount  bool
+	Transient  bool
+}
+
+// Lines of shared folders in "showvminfo", e.g
+// Name: 'src', Host path: '/home/user/src' (machine mapping), writable, auto-mount, mount-point: '/src'
+var reSharedFolder = regexp.MustCompile(`^Name: '(.*)', Host path: '(.*)' \((machine|transient) mapping\), (writable|readonly)(, auto-mount)?(?:, mount-point: '(.*)')?$`)
+
+// parseSharedFolders parses shared folders from "showvminfo" output
+func parseSharedFolders(out string) []SharedFolder {
+	folders := []SharedFolder{}
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		res := reSharedFolder.FindStringSubmatch(strings.TrimSpace(s.Text()))
+		if res == nil {
+			continue
+		}
+		folders = append(folders, SharedFolder{
+			Name:       res[1],
+			HostPath:   res[2],
+			Transient:  res[3] == "transient",
+			ReadOnly:   res[4] == "readonly",
+			AutoMount:  res[5] != "",
+			MountPoint: res[6],
+		})
+	}
+	return folders
+}
+
+// SharedFolders returns shared folders of VM
+func SharedFolders(vb *vbg.VBox, vm *vbg.VirtualMachine) ([]SharedFolder, error) {
+	out, err := Manage(vb, "showvminfo", vm.UUIDOrName())
+	if err != nil {
+		return nil, fmt.Errorf("showvminfo failed: %s", err.Error())
+	}
+	return parseSharedFolders(out), nil
+}
+
+// AddSharedFolder shares host folder with VM, transient folder can only be added to running VM
+func AddSharedFolder(vb *vbg.VBox, vm *vbg.VirtualMachine, folder SharedFolder) error {
+	args := []string{"sharedfolder", "add", vm.UUIDOrName(), "--name", folder.Name, "--hostpath", folder.HostPath}
+	if folder.ReadOnly {
+		args = append(args, "--readonly")
+	}
+	if folder.AutoMount {
+		args = append(args, "--automount")
+	}
+	if folder.MountPoint != "" {
+		args = append(args, "--auto-mount-point", folder.MountPoint)
+	}
+	if folder.Transient {
+		args = append(args, "--transient")
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("sharedfolder add failed: %s", err.Error())
+	}
+	return nil
+}
+
+// RemoveSharedFolder stops sharing folder with VM
+func RemoveSharedFolder(vb *vbg.VBox, vm *vbg.VirtualMachine, folder SharedFolder) error {
+	args := []string{"sharedfolder", "remove", vm.UUIDOrName(), "--name", folder.Name}
+	if folder.Transient {
+		args = append(args, "--transient")
+	}
+
+	if _, err := Manage(vb, args...); err != nil {
+		return fmt.Errorf("sharedfolder remove failed: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseSharedFolders(t *testing.T) {
+	out := `Name:                        test
+Shared folders:
+
+Name: 'src', Host path: '/home/user/src' (machine mapping), writable, auto-mount, mount-point: '/src'
+Name: 'data', Host path: '/srv/data' (machine mapping), readonly
+Name: 'tmp', Host path: '/tmp' (transient mapping), writable
+
+VRDE Connection:             not active
+`
+
+	expected := []SharedFolder{
+		{Name: "src", HostPath: "/home/user/src", AutoMount: true, MountPoint: "/src"},
+		{Name: "data", HostPath: "/srv/data", ReadOnly: true},
+		{Name: "tmp", HostPath: "/tmp", Transient: true},
+	}
+
+	if folders := parseSharedFolders(out); !reflect.DeepEqual(folders, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, folders)
+	}
+}
@@ -0,0 +1,316 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+)
+
+// StorageDisk describes data disk attached to virtual machine
+// disk with DiskID is managed outside of VM and is never created or deleted with it
+type StorageDisk struct {
+	Path          string
+	UUID          string
+	DiskID        string
+	SizeMB        int64
+	Format        string
+	Controller    string
+	Port          int
+	Device        int
+	NonRotational bool
+	HotPluggable  bool
+	Discard       bool
+}
+
+// Slot returns key identifying place of disk on storage controllers
+func (disk StorageDisk) Slot() string {
+	return fmt.Sprintf("%s-%d-%d", disk.Controller, disk.Port, disk.Device)
+}
+
+// Medium returns UUID of disk if it is known and its path otherwise
+func (disk StorageDisk) Medium() string {
+	if disk.UUID != "" {
+		return disk.UUID
+	}
+	return disk.Path
+}
+
+// Controllers supported for data disks: name of controller in VM, bus and chipset for "storagectl"
+var controllers = map[string][3]string{
+	"sata":        {"SATA Controller", "sata", "IntelAhci"},
+	"scsi":        {"SCSI Controller", "scsi", "LsiLogic"},
+	"nvme":        {"NVMe Controller", "pcie", "NVMe"},
+	"virtio-scsi": {"VirtIO Controller", "virtio", "VirtIO"},
+	"ide":         {"IDE Controller", "ide", "PIIX4"},
+}
+
+// ControllerTypes returns names of supported controller types
+func ControllerTypes() []string {
+	return []string{"sata", "scsi", "nvme", "virtio-scsi", "ide"}
+}
+
+// ControllerName returns name of storage controller of given type in VM
+func ControllerName(controller string) string {
+	return controllers[controller][0]
+}
+
+// EnsureController adds storage controller of given type to VM unless it is already there
+func EnsureController(vb *vbg.VBox, vm *vbg.VirtualMachine, controller string) error {
+	ctl, ok := controllers[controller]
+	if !ok {
+		return fmt.Errorf("unknown controller type %s", controller)
+	}
+
+	info, err := VMInfoMap(vb, vm.UUIDOrName())
+	if err != nil {
+		return err
+	}
+
+	for i := 0; ; i++ {
+		name, ok := info[fmt.Sprintf("storagecontrollername%d", i)]
+		if !ok {
+			break
+		}
+		if name == ctl[0] {
+			return nil
+		}
+	}
+
+	_, err = Manage(vb, "storagectl", vm.UUIDOrName(), "--name", ctl[0], "--add", ctl[1], "--controller", ctl[2])
+	return err
+}
+
+// AttachDisk creates medium of disk if it does not exist yet and attaches it to VM
+func AttachDisk(vb *vbg.VBox, vm *vbg.VirtualMachine, disk *StorageDisk) error {
+	medium := disk.Path
+	if disk.DiskID != "" {
+		medium = disk.DiskID
+	} else if _, err := os.Stat(disk.Path); os.IsNotExist(err) {
+		if err := CreateDisk(vb, disk.Path, disk.SizeMB, disk.Format, "dynamic"); err != nil {
+			return err
+		}
+	}
+
+	if err := EnsureController(vb, vm, disk.Controller); err != nil {
+		return fmt.Errorf("add %s controller error: %s", disk.Controller, err.Error())
+	}
+
+	if _, err := Manage(vb, "storageattach", vm.UUIDOrName(),
+		"--storagectl", ControllerName(disk.Controller),
+		"--port", strconv.Itoa(disk.Port),
+		"--device", strconv.Itoa(disk.Device),
+		"--type", "hdd",
+		"--medium", medium,
+		"--nonrotational", onOff(disk.NonRotational),
+		"--hotpluggable", onOff(disk.HotPluggable),
+		"--discard", onOff(disk.Discard)); err != nil {
+		return fmt.Errorf("attach error: %s", err.Error())
+	}
+
+	info, err := MediumInfo(vb, medium)
+	if err != nil {
+		return err
+	}
+	disk.UUID = info["UUID"]
+	disk.Path = info["Location"]
+
+	return nil
+}
+
+// DetachDisk detaches disk from VM, medium is deleted if remove is true and disk is not managed outside of VM
+func DetachDisk(vb *vbg.VBox, vm *vbg.VirtualMachine, disk StorageDisk, remove bool) error {
+	if _, err := Manage(vb, "storageattach", vm.UUIDOrName(),
+		"--storagectl", ControllerName(disk.Controller),
+		"--port", strconv.Itoa(disk.Port),
+		"--device", strconv.Itoa(disk.Device),
+		"--medium", "none"); err != nil {
+		return fmt.Errorf("detach error: %s", err.Error())
+	}
+
+	if remove && disk.DiskID == "" {
+		if _, err := Manage(vb, "closemedium", "disk", disk.Medium(), "--delete"); err != nil {
+			return fmt.Errorf("disk deletion failed: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// AttachedDisk returns path and UUID of medium attached to slot of disk, empty if slot is free
+func AttachedDisk(info map[string]string, disk StorageDisk) (string, string) {
+	name := ControllerName(disk.Controller)
+	path := info[fmt.Sprintf("%s-%d-%d", name, disk.Port, disk.Device)]
+	if path == "none" || path == "emptydrive" {
+		return "", ""
+	}
+	return path, info[fmt.Sprintf("%s-ImageUUID-%d-%d", name, disk.Port, disk.Device)]
+}
+
+// MediumInfo returns "showmediuminfo" output of disk as map
+func MediumInfo(vb *vbg.VBox, uuidOrPath string) (map[string]string, error) {
+	out, err := Manage(vb, "showmediuminfo", "disk", uuidOrPath)
+	if err != nil {
+		return nil, fmt.Errorf("showmediuminfo failed: %s", err.Error())
+	}
+
+	info := make(map[string]string)
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		key, val, ok := strings.Cut(s.Text(), ":")
+		if !ok {
+			continue
+		}
+		info[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return info, nil
+}
+
+// MediumSizeMB returns logical size of disk in MB
+func MediumSizeMB(info map[string]string) (int64, error) {
+	// e.g "Capacity: 15000 MBytes"
+	capacity := strings.Fields(info["Capacity"])
+	if len(capacity) == 0 {
+		return 0, fmt.Errorf("capacity of medium is unknown")
+	}
+
+	size, err := strconv.ParseInt(capacity[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("wrong capacity %s: %s", info["Capacity"], err.Error())
+	}
+
+	if len(capacity) > 1 {
+		switch capacity[1] {
+		case "GBytes":
+			size *= 1024
+		case "TBytes":
+			size *= 1024 * 1024
+		}
+	}
+	return size, nil
+}
+
+// ResizeDisk changes logical size of disk, disks can only grow
+func ResizeDisk(vb *vbg.VBox, uuidOrPath string, sizeMB int64) error {
+	if _, err := Manage(vb, "modifymedium", "disk", uuidOrPath, "--resize", strconv.FormatInt(sizeMB, 10)); err != nil {
+		return fmt.Errorf("disk resize failed: %s", err.Error())
+	}
+	return nil
+}
+
+// DiskFormats returns formats of disk which can be created
+func DiskFormats() []string {
+	return []string{"vdi", "vmdk", "vhd"}
+}
+
+// DiskVariants returns variants of disk which can be created
+func DiskVariants() []string {
+	return []string{"dynamic", "fixed"}
+}
+
+// CreateDisk creates new disk of given format (vdi | vmdk | vhd) and variant (dynamic | fixed)
+// virtualbox-go creates only dynamic disks, so fixed ones are created by createmedium directly
+func CreateDisk(vb *vbg.VBox, path string, sizeMB int64, format string, variant string) error {
+	if variant != "fixed" {
+		disk := &vbg.Disk{Path: path, SizeMB: sizeMB, Format: vbg.DiskFormat(strings.ToUpper(format))}
+		if err := vb.CreateDisk(disk); err != nil {
+			return fmt.Errorf("disk creation failed: %s", err.Error())
+		}
+		return nil
+	}
+
+	if _, err := Manage(vb, "createmedium", "disk", "--filename", path,
+		"--size", strconv.FormatInt(sizeMB, 10),
+		"--format", strings.ToUpper(format),
+		"--variant", diskVariant(variant)); err != nil {
+		return fmt.Errorf("disk creation failed: %s", err.Error())
+	}
+	return nil
+}
+
+// CloneDisk copies disk src to new disk at path with given format and variant
+func CloneDisk(vb *vbg.VBox, src string, path string, format string, variant string) error {
+	if _, err := Manage(vb, "clonemedium", "disk", src, path,
+		"--format", strings.ToUpper(format),
+		"--variant", diskVariant(variant)); err != nil {
+		return fmt.Errorf("disk cloning failed: %s", err.Error())
+	}
+	return nil
+}
+
+// CopyDisk copies disk image src to new disk dest with its own UUID, format is taken from extension of dest
+// src is closed afterwards, so that it can be removed without breaking media registry,
+// copies of shared src must not run in parallel, e.g cached image is copied under lock of its entry
+func CopyDisk(vb *vbg.VBox, src string, dest string) error {
+	format := strings.TrimPrefix(filepath.Ext(dest), ".")
+	if err := CloneDisk(vb, src, dest, format, "dynamic"); err != nil {
+		return err
+	}
+
+	if _, err := Manage(vb, "closemedium", "disk", src); err != nil {
+		return fmt.Errorf("closing disk %s failed: %s", src, err.Error())
+	}
+	return nil
+}
+
+// CopyImage copies ISO image src to dest, which is attached instead of src,
+// so that VM does not depend on file in image cache which can be evicted
+func CopyImage(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0740); err != nil {
+		return err
+	}
+
+	// Partial copy is never left at dest
+	out, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copying image %s failed: %s", src, err.Error())
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(out.Name(), 0640); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dest)
+}
+
+// CompactDisk reduces size of dynamic disk image by removing zeroed blocks
+func CompactDisk(vb *vbg.VBox, uuidOrPath string) error {
+	if _, err := Manage(vb, "modifymedium", "disk", uuidOrPath, "--compact"); err != nil {
+		return fmt.Errorf("disk compacting failed: %s", err.Error())
+	}
+	return nil
+}
+
+// MediumVariant returns variant of disk (dynamic | fixed)
+func MediumVariant(info map[string]string) string {
+	// e.g "Format variant: fixed default"
+	if strings.Contains(info["Format variant"], "fixed") {
+		return "fixed"
+	}
+	return "dynamic"
+}
+
+func diskVariant(variant string) string {
+	if variant == "fixed" {
+		return "Fixed"
+	}
+	return "Standard"
+}
@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_MediumSizeMB(t *testing.T) {
+	tests := map[string]int64{
+		"15000 MBytes": 15000,
+		"2 GBytes":     2048,
+		"1 TBytes":     1024 * 1024,
+	}
+
+	for capacity, expected := range tests {
+		size, err := MediumSizeMB(map[string]string{"Capacity": capacity})
+		if err != nil {
+			t.Fatalf("MediumSizeMB failed for %s: %v", capacity, err)
+		}
+		if size != expected {
+			t.Errorf("Expected size for %s: %v, actual size: %v", capacity, expected, size)
+		}
+	}
+
+	if _, err := MediumSizeMB(map[string]string{}); err == nil {
+		t.Errorf("Expected error for unknown capacity")
+	}
+}
+
+func Test_CopyImage(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "cache", "ubuntu.iso")
+	if err := os.MkdirAll(filepath.Dir(src), 0740); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("iso image"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, "InstalledData", "vm", "ubuntu.iso")
+	if err := CopyImage(src, dest); err != nil {
+		t.Fatalf("CopyImage failed: %v", err)
+	}
+
+	// Copy stays usable after cache entry is evicted
+	if err := os.RemoveAll(filepath.Dir(src)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("Copy of image is missing: %v", err)
+	}
+	if string(data) != "iso image" {
+		t.Errorf("Expected content %q, actual %q", "iso image", data)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(dest))
+	if len(entries) != 1 {
+		t.Errorf("Expected only copied image in folder, found %d entries", len(entries))
+	}
+}
@@ -1,68 +1,278 @@
 package pkg
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/mholt/archiver"
 	"github.com/sirupsen/logrus"
 )
 
-// download file from url
-func FileDownload(url, fpath string) (string, error) {
-	file := path.Base(url)
-	logrus.Printf("Dowloading file %s from %s\n", file, url)
-	var path bytes.Buffer
-	path.WriteString(filepath.Join(fpath, file))
+// downloadAttempts is how many times interrupted download is resumed before giving up
+const downloadAttempts = 3
+
+// errInterrupted marks download errors after which download can be resumed
+var errInterrupted = errors.New("download interrupted")
 
-	out, err := os.Create(path.String())
+// urlFileName returns name of file from url, query and fragment of url are not part of it
+func urlFileName(url string) string {
+	if u, err := neturl.Parse(url); err == nil && u.Path != "" {
+		return path.Base(u.Path)
+	}
+	return path.Base(url)
+}
+
+// download file from url into fpath and verify it against checksum (see ParseChecksum)
+// file is written to "<name>.part" first, so that interrupted download is resumed
+// with HTTP Range request and never mistaken for complete one
+func FileDownload(url, fpath, checksum string) (string, error) {
+	file := urlFileName(url)
+	dest := filepath.Join(fpath, file)
+
+	sum, err := ParseChecksum(checksum, url)
 	if err != nil {
-		return "", fmt.Errorf("creation file failed: %s", err.Error())
+		return "", err
+	}
+
+	// File downloaded before is reused if it is known to be correct
+	if sum != nil {
+		if _, err := os.Stat(dest); err == nil && sum.Verify(dest) == nil {
+			logrus.Printf("File %s is already downloaded\n", dest)
+			return dest, nil
+		}
+	}
+
+	logrus.Printf("Dowloading file %s from %s\n", file, url)
+	part := dest + ".part"
+
+	// Part left by earlier download may come from changed upstream file,
+	// so it is only resumed when result is verified by checksum
+	if sum == nil {
+		if err := os.Remove(part); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("removing partial download failed: %s", err.Error())
+		}
 	}
-	defer out.Close()
 
-	headResp, err := http.Head(url)
+	for attempt := 1; ; attempt++ {
+		err = downloadPart(url, part)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errInterrupted) || attempt == downloadAttempts {
+			return "", err
+		}
+		logrus.Warnf("Download of %s interrupted, resuming: %s", url, err.Error())
+	}
+
+	if sum != nil {
+		if err := sum.Verify(part); err != nil {
+			os.Remove(part)
+			return "", err
+		}
+	}
+
+	if err := os.Rename(part, dest); err != nil {
+		return "", fmt.Errorf("rename of downloaded file failed: %s", err.Error())
+	}
+
+	logrus.Print("Downloading completed")
+	return dest, nil
+}
+
+// downloadPart downloads url into part, continuing from the end of part if it exists
+func downloadPart(url, part string) error {
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("http head failed: %s", err.Error())
+		return fmt.Errorf("http request failed: %s", err.Error())
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
-	defer headResp.Body.Close()
 
-	resp, err := http.Get(url)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("http get failed: %s", err.Error())
+		return fmt.Errorf("%w: http get failed: %s", errInterrupted, err.Error())
 	}
 	defer resp.Body.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// Server does not support ranges, starting from scratch
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Part is complete or bigger than file, it is checked by checksum or downloaded again
+		if offset > 0 && resp.Header.Get("Content-Range") == fmt.Sprintf("bytes */%d", offset) {
+			return nil
+		}
+		os.Remove(part)
+		return fmt.Errorf("http get failed: %s", resp.Status)
+	default:
+		return fmt.Errorf("http get %s failed: %s", url, resp.Status)
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return fmt.Errorf("%s returned HTML page instead of file", url)
+	}
+
+	out, err := os.OpenFile(part, flags, 0640)
 	if err != nil {
-		return "", fmt.Errorf("hopy failed: %s", err.Error())
+		return fmt.Errorf("creation file failed: %s", err.Error())
 	}
+	defer out.Close()
 
-	logrus.Print("Downloading completed")
-	return path.String(), nil
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return fmt.Errorf("%w: copy failed: %s", errInterrupted, err.Error())
+	}
+	return nil
 }
 
-// return path to image or virtual disk and error
-func UnpackImage(imageArchive, destDir string) (string, error) {
+// unpack archive into its own directory inside destDir named after archive and return path to image or virtual disk in it
+// entry is path of the file inside archive, if it is empty the only disk or ISO is looked up by content
+func UnpackImage(imageArchive, destDir, entry string) (string, error) {
 	a, err := os.Open(imageArchive)
 	if err != nil {
 		return "", fmt.Errorf("open archive failed: %s", err.Error())
 	}
 	defer a.Close()
 
+	// e.g "ubuntu.tar.xz" is unpacked into "ubuntu"
+	name := strings.TrimSuffix(filepath.Base(imageArchive), filepath.Ext(imageArchive))
+	name = strings.TrimSuffix(name, ".tar")
+	destDir = filepath.Join(destDir, name)
+
+	// Leftovers of previous unpacking make archiver fail
+	if err := os.RemoveAll(destDir); err != nil {
+		return "", fmt.Errorf("cleaning %s failed: %s", destDir, err.Error())
+	}
+	if err := os.MkdirAll(destDir, 0740); err != nil {
+		return "", fmt.Errorf("creation %s failed: %s", destDir, err.Error())
+	}
+
 	if err = archiver.Unarchive(imageArchive, destDir); err != nil {
 		return "", fmt.Errorf("unarchiving failed: %s", err.Error())
 	}
 
-	files, err := os.ReadDir(destDir)
+	var image, format string
+	if entry != "" {
+		image = filepath.Join(destDir, filepath.FromSlash(entry))
+		if rel, err := filepath.Rel(destDir, image); err != nil || strings.HasPrefix(rel, "..") {
+			return "", fmt.Errorf("archive entry %s is outside of archive", entry)
+		}
+		if format, err = DetectImage(image); err != nil {
+			return "", fmt.Errorf("archive entry %s: %s", entry, err.Error())
+		}
+		if format == "" {
+			return "", fmt.Errorf("archive entry %s is neither disk nor ISO", entry)
+		}
+	} else {
+		var found []string
+		err := filepath.WalkDir(destDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			f, err := DetectImage(path)
+			if err != nil {
+				return err
+			}
+			if f != "" {
+				image, format = path, f
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("looking for image failed: %s", err.Error())
+		}
+
+		switch len(found) {
+		case 0:
+			return "", fmt.Errorf("no disk or ISO found in %s", filepath.Base(imageArchive))
+		case 1:
+		default:
+			for i := range found {
+				found[i], _ = filepath.Rel(destDir, found[i])
+			}
+			return "", fmt.Errorf("several images found in %s, choose one with archive_entry: %s",
+				filepath.Base(imageArchive), strings.Join(found, ", "))
+		}
+	}
+
+	// VirtualBox relies on extension of file, qcow2 and raw images are converted to VDI later
+	if ext := "." + format; format != "raw" && filepath.Ext(image) != ext {
+		if err := os.Rename(image, image+ext); err != nil {
+			return "", fmt.Errorf("rename of image failed: %s", err.Error())
+		}
+		image += ext
+	}
+
+	return image, nil
+}
+
+// imageMagics are signatures of images: format, offset from start (negative from end) and bytes
+var imageMagics = []struct {
+	format string
+	offset int64
+	magic  string
+}{
+	{"vdi", 0x40, "\x7f\x10\xda\xbe"},
+	{"vmdk", 0, "KDMV"},
+	{"vmdk", 0, "# Disk DescriptorFile"},
+	{"vhd", 0, "conectix"},
+	{"vhd", -512, "conectix"},
+	{"iso", 0x8001, "CD001"},
+	{"qcow2", 0, "QFI\xfb"},
+}
+
+// DetectImage returns format of disk or ISO image at path by its content (vdi | vmdk | vhd | iso | qcow2),
+// file without signature is raw disk if it has .raw or .img extension, otherwise empty string is returned
+func DetectImage(path string) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("read dir failde: %s", err.Error())
+		return "", err
 	}
+	defer f.Close()
 
-	return filepath.Join(destDir, files[0].Name()), nil
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	for _, m := range imageMagics {
+		offset := m.offset
+		if offset < 0 {
+			offset += info.Size()
+		}
+		if offset < 0 || offset+int64(len(m.magic)) > info.Size() {
+			continue
+		}
+
+		buf := make([]byte, len(m.magic))
+		if _, err := f.ReadAt(buf, offset); err != nil {
+			return "", err
+		}
+		if string(buf) == m.magic {
+			return m.format, nil
+		}
+	}
+
+	if ext := filepath.Ext(path); ext == ".raw" || ext == ".img" {
+		return "raw", nil
+	}
+	return "", nil
 }
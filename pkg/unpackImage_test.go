@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mholt/archiver"
+)
+
+// writeVDISignature writes file with VDI signature
+func writeVDISignature(t *testing.T, path string) {
+	data := make([]byte, 512)
+	copy(data[0x40:], "\x7f\x10\xda\xbe")
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_DetectImage(t *testing.T) {
+	dir := t.TempDir()
+
+	vdi := filepath.Join(dir, "disk")
+	writeVDISignature(t, vdi)
+
+	vhd := filepath.Join(dir, "fixed.vhd")
+	data := make([]byte, 2048)
+	copy(data[len(data)-512:], "conectix")
+	if err := os.WriteFile(vhd, data, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	readme := filepath.Join(dir, "README")
+	if err := os.WriteFile(readme, []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]string{vdi: "vdi", vhd: "vhd", readme: ""} {
+		format, err := DetectImage(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if format != expected {
+			t.Errorf("%s: expected %q, actual %q", filepath.Base(path), expected, format)
+		}
+	}
+}
+
+func Test_UnpackImageLookup(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "README"), []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(src, "images"), 0740); err != nil {
+		t.Fatal(err)
+	}
+	writeVDISignature(t, filepath.Join(src, "images", "disk"))
+
+	archive := filepath.Join(t.TempDir(), "appliance.tar")
+	if err := archiver.Archive([]string{filepath.Join(src, "README"), filepath.Join(src, "images")}, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	image, err := UnpackImage(archive, dest, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if expected := filepath.Join(dest, "appliance", "images", "disk.vdi"); image != expected {
+		t.Errorf("Expected %s, actual %s", expected, image)
+	}
+
+	// Second disk makes lookup ambiguous
+	writeVDISignature(t, filepath.Join(src, "images", "other"))
+	if err := os.Remove(archive); err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Archive([]string{filepath.Join(src, "README"), filepath.Join(src, "images")}, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := UnpackImage(archive, dest, ""); err == nil || !strings.Contains(err.Error(), "archive_entry") {
+		t.Errorf("Expected error asking for archive_entry, actual: %v", err)
+	}
+
+	image, err = UnpackImage(archive, dest, "images/other")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if expected := filepath.Join(dest, "appliance", "images", "other.vdi"); image != expected {
+		t.Errorf("Expected %s, actual %s", expected, image)
+	}
+
+	if _, err := UnpackImage(archive, dest, "README"); err == nil {
+		t.Errorf("Expected error for entry which is not an image")
+	}
+}
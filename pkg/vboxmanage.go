@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	vbg "github.com/mixdone/virtualbox-go"
+	"github.com/sirupsen/logrus"
+)
+
+// SetVBoxManagePath makes VBoxManage from directory dir the one used by virtualbox-go,
+// which always runs "VBoxManage" found in PATH, so executable with other name can't be used.
+// Manage runs the same executable when VirtualBoxPath of vb is dir
+func SetVBoxManagePath(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("VBoxManage folder not found: %s", err.Error())
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder, set folder containing %s", dir, vbg.VBoxManage)
+	}
+
+	if _, err := exec.LookPath(filepath.Join(dir, vbg.VBoxManage)); err != nil {
+		return fmt.Errorf("%s not found in %s: %s", vbg.VBoxManage, dir, err.Error())
+	}
+
+	return os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// Manage runs VBoxManage with given arguments for commands virtualbox-go does not cover.
+// returns stdout of command, or stderr as error if command failed
+func Manage(vb *vbg.VBox, args ...string) (string, error) {
+	program := vbg.VBoxManage
+	if vb.Config.VirtualBoxPath != "" {
+		program = filepath.Join(vb.Config.VirtualBoxPath, vbg.VBoxManage)
+	}
+
+	logrus.Debugf("COMMAND: %s %s", program, strings.Join(args, " "))
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command(program, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var ee *exec.Error
+		if errors.As(err, &ee) {
+			return "", fmt.Errorf("unable to find VBoxManage command: %s", ee.Error())
+		}
+		return stdout.String(), vbg.VBoxError(stderr.String())
+	}
+
+	return stdout.String(), nil
+}
+
+// VMInfoMap returns "showvminfo --machinereadable" output of VM as map
+// it gives access to settings that vbg.VMInfo does not parse
+func VMInfoMap(vb *vbg.VBox, uuidOrName string) (map[string]string, error) {
+	out, err := Manage(vb, "showvminfo", uuidOrName, "--machinereadable")
+	if err != nil {
+		return nil, vbg.ErrMachineNotExist
+	}
+
+	return parseMachineReadable(out), nil
+}
+
+// parseMachineReadable parses lines like key="value" or "key"=value
+// lines which can't be parsed are logged, so that missing settings can be explained
+func parseMachineReadable(out string) map[string]string {
+	info := make(map[string]string)
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		key, val, ok := strings.Cut(s.Text(), "=")
+		if !ok {
+			if strings.TrimSpace(s.Text()) != "" {
+				logrus.Warnf("Unable to parse line of machine readable output: %q", s.Text())
+			}
+			continue
+		}
+		if k, err := strconv.Unquote(key); err == nil {
+			key = k
+		}
+		if v, err := strconv.Unquote(val); err == nil {
+			val = v
+		} else if strings.HasPrefix(val, `"`) {
+			logrus.Warnf("Unable to parse value of %s in machine readable output: %s", key, val)
+		}
+		info[key] = val
+	}
+	return info
+}
+
+var reVMLine = regexp.MustCompile(`^"(.*)" \{([0-9a-fA-F-]+)\}$`)
+
+// ListVMs returns names of all registered virtual machines keyed by UUID
+func ListVMs(vb *vbg.VBox) (map[string]string, error) {
+	out, err := Manage(vb, "list", "vms")
+	if err != nil {
+		return nil, fmt.Errorf("list vms failed: %s", err.Error())
+	}
+
+	vms := make(map[string]string)
+	s := bufio.NewScanner(strings.NewReader(out))
+	for s.Scan() {
+		if res := reVMLine.FindStringSubmatch(strings.TrimSpace(s.Text())); res != nil {
+			vms[res[2]] = res[1]
+		}
+	}
+	return vms, nil
+}
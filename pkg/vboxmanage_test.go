@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func Test_parseMachineReadable(t *testing.T) {
+	out := "name=\"vm 1\"\n" +
+		"groups=\"/lab\"\n" +
+		"memory=1024\n" +
+		"\"SATA Controller-0-0\"=\"/home/user/VMs/disk.vdi\"\n" +
+		"garbage line\n"
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	info := parseMachineReadable(out)
+
+	if len(hook.Entries) != 1 || !strings.Contains(hook.Entries[0].Message, "garbage line") {
+		t.Errorf("Expected warning about unparsed line, got %v", hook.AllEntries())
+	}
+
+	expected := map[string]string{
+		"name":                "vm 1",
+		"groups":              "/lab",
+		"memory":              "1024",
+		"SATA Controller-0-0": "/home/user/VMs/disk.vdi",
+	}
+
+	if len(info) != len(expected) {
+		t.Fatalf("Expected %d keys, actual %d: %v", len(expected), len(info), info)
+	}
+
+	for key, val := range expected {
+		if info[key] != val {
+			t.Errorf("Expected %s: %v, actual: %v", key, val, info[key])
+		}
+	}
+}
+
+func Test_SetVBoxManagePath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("VBoxManage is VBoxManage.exe on windows")
+	}
+	t.Setenv("PATH", os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	if err := SetVBoxManagePath(dir); err == nil {
+		t.Errorf("Expected error for folder without VBoxManage")
+	}
+
+	program := filepath.Join(dir, "VBoxManage")
+	if err := os.WriteFile(program, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetVBoxManagePath(program); err == nil {
+		t.Errorf("Expected error for path of executable")
+	}
+
+	if err := SetVBoxManagePath(dir); err != nil {
+		t.Fatalf("SetVBoxManagePath failed: %v", err)
+	}
+	if !strings.HasPrefix(os.Getenv("PATH"), dir) {
+		t.Errorf("Expected %s at start of PATH, actual PATH: %s", dir, os.Getenv("PATH"))
+	}
+}